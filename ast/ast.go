@@ -0,0 +1,363 @@
+// Package ast defines the syntax tree of a markdown document
+package ast
+
+// Kind identifies the type of a node
+type Kind int
+
+type kind struct {
+	name  string
+	block bool
+}
+
+var kinds []kind
+
+// NewBlockKind registers a new kind of block node, e.g. for an extension
+func NewBlockKind(name string) Kind {
+	kinds = append(kinds, kind{name: name, block: true})
+	return Kind(len(kinds) - 1)
+}
+
+// NewInlineKind registers a new kind of inline node, e.g. for an extension
+func NewInlineKind(name string) Kind {
+	kinds = append(kinds, kind{name: name})
+	return Kind(len(kinds) - 1)
+}
+
+func (k Kind) String() string {
+	return kinds[k].name
+}
+
+// IsBlock reports whether nodes of kind k are blocks.
+// Sibling blocks are rendered on separate lines.
+func (k Kind) IsBlock() bool {
+	return kinds[k].block
+}
+
+var (
+	// block
+	KindDocument   = NewBlockKind("Document")
+	KindHeading    = NewBlockKind("Heading")
+	KindBlockquote = NewBlockKind("Blockquote")
+	KindList       = NewBlockKind("List")
+	KindListItem   = NewBlockKind("ListItem")
+	KindCodeBlock  = NewBlockKind("CodeBlock")
+	KindParagraph  = NewBlockKind("Paragraph")
+	KindHTMLBlock  = NewBlockKind("HTMLBlock")
+	KindTOC        = NewBlockKind("TOC")
+	KindFootnotes  = NewBlockKind("Footnotes")
+	KindFootnote   = NewBlockKind("Footnote")
+	KindTable      = NewBlockKind("Table")
+	KindTableRow   = NewBlockKind("TableRow")
+	KindTableCell  = NewBlockKind("TableCell")
+
+	// inline
+	KindText          = NewInlineKind("Text")
+	KindEmphasis      = NewInlineKind("Emphasis")
+	KindStrong        = NewInlineKind("Strong")
+	KindStrikethrough = NewInlineKind("Strikethrough")
+	KindCodeSpan      = NewInlineKind("CodeSpan")
+	KindLink          = NewInlineKind("Link")
+	KindImage         = NewInlineKind("Image")
+	KindFootnoteRef   = NewInlineKind("FootnoteRef")
+)
+
+// Position is a location in the source.
+// Line and Col start at 1 and Col counts bytes.
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Col    int `json:"col"`
+}
+
+// Span is the source range of a node, End is exclusive.
+// Nodes without source, like the footnotes section or nodes added by
+// transformers, have a zero Span.
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// IsZero reports whether s has no source
+func (s Span) IsZero() bool {
+	return s == Span{}
+}
+
+// Node is a node of the syntax tree.
+// Custom nodes embed BaseNode and implement Kind.
+type Node interface {
+	Kind() Kind
+	Span() Span
+	SetSpan(span Span)
+	Parent() Node
+	FirstChild() Node
+	LastChild() Node
+	PrevSibling() Node
+	NextSibling() Node
+
+	base() *BaseNode
+}
+
+// BaseNode links a node into the tree and records its source
+type BaseNode struct {
+	span   Span
+	parent Node
+	first  Node
+	last   Node
+	prev   Node
+	next   Node
+}
+
+func (n *BaseNode) Span() Span        { return n.span }
+func (n *BaseNode) SetSpan(span Span) { n.span = span }
+func (n *BaseNode) Parent() Node      { return n.parent }
+func (n *BaseNode) FirstChild() Node  { return n.first }
+func (n *BaseNode) LastChild() Node   { return n.last }
+func (n *BaseNode) PrevSibling() Node { return n.prev }
+func (n *BaseNode) NextSibling() Node { return n.next }
+
+func (n *BaseNode) base() *BaseNode { return n }
+
+// block nodes
+
+// Document is the root of the tree
+type Document struct {
+	BaseNode
+	// Meta is the front matter of the document
+	Meta map[string]interface{}
+}
+
+// Heading contains inline nodes
+type Heading struct {
+	BaseNode
+	Level int
+	ID    string
+}
+
+// Blockquote contains inline nodes of a single quoted line
+type Blockquote struct {
+	BaseNode
+	Level int
+}
+
+// List contains list items and nested lists
+type List struct {
+	BaseNode
+	// Ordered lists are numbered from Start
+	Ordered bool
+	Start   int
+}
+
+// ListItem contains inline nodes
+type ListItem struct {
+	BaseNode
+}
+
+// CodeBlock is a fenced code block. Code is html escaped.
+type CodeBlock struct {
+	BaseNode
+	Lang []byte
+	Code []byte
+}
+
+// Paragraph contains inline nodes
+type Paragraph struct {
+	BaseNode
+}
+
+// HTMLBlock is raw html
+type HTMLBlock struct {
+	BaseNode
+	Raw []byte
+}
+
+// TOC is replaced by the table of contents
+type TOC struct {
+	BaseNode
+}
+
+// Footnotes contains the referenced footnotes of a document
+type Footnotes struct {
+	BaseNode
+}
+
+// Footnote contains the blocks of a footnote definition
+type Footnote struct {
+	BaseNode
+	Num  int
+	Refs int
+}
+
+// Table contains table rows, the first is the header
+type Table struct {
+	BaseNode
+}
+
+// TableRow contains a cell for each column of the table
+type TableRow struct {
+	BaseNode
+	Header bool
+}
+
+// TableCell contains inline nodes
+type TableCell struct {
+	BaseNode
+	// Align is "left", "center", "right" or empty
+	Align string
+}
+
+// inline nodes
+
+// Text is raw text
+type Text struct {
+	BaseNode
+	Value []byte
+}
+
+// Emphasis contains inline nodes
+type Emphasis struct {
+	BaseNode
+}
+
+// Strong contains inline nodes
+type Strong struct {
+	BaseNode
+}
+
+// Strikethrough contains inline nodes
+type Strikethrough struct {
+	BaseNode
+}
+
+// CodeSpan is inline code
+type CodeSpan struct {
+	BaseNode
+	Code []byte
+}
+
+// Link contains the inline nodes of the link text
+type Link struct {
+	BaseNode
+	Dest []byte
+	// Ref is the label of a reference link, empty for an inline link
+	Ref string
+}
+
+// Image is an image with its alternative text
+type Image struct {
+	BaseNode
+	Dest []byte
+	Alt  []byte
+	// Ref is the label of a reference image, empty for an inline image
+	Ref string
+}
+
+// FootnoteRef is the ref-th reference to footnote num
+type FootnoteRef struct {
+	BaseNode
+	Num int
+	Ref int
+}
+
+func (n *Document) Kind() Kind      { return KindDocument }
+func (n *Heading) Kind() Kind       { return KindHeading }
+func (n *Blockquote) Kind() Kind    { return KindBlockquote }
+func (n *List) Kind() Kind          { return KindList }
+func (n *ListItem) Kind() Kind      { return KindListItem }
+func (n *CodeBlock) Kind() Kind     { return KindCodeBlock }
+func (n *Paragraph) Kind() Kind     { return KindParagraph }
+func (n *HTMLBlock) Kind() Kind     { return KindHTMLBlock }
+func (n *TOC) Kind() Kind           { return KindTOC }
+func (n *Footnotes) Kind() Kind     { return KindFootnotes }
+func (n *Footnote) Kind() Kind      { return KindFootnote }
+func (n *Table) Kind() Kind         { return KindTable }
+func (n *TableRow) Kind() Kind      { return KindTableRow }
+func (n *TableCell) Kind() Kind     { return KindTableCell }
+func (n *Text) Kind() Kind          { return KindText }
+func (n *Emphasis) Kind() Kind      { return KindEmphasis }
+func (n *Strong) Kind() Kind        { return KindStrong }
+func (n *Strikethrough) Kind() Kind { return KindStrikethrough }
+func (n *CodeSpan) Kind() Kind      { return KindCodeSpan }
+func (n *Link) Kind() Kind          { return KindLink }
+func (n *Image) Kind() Kind         { return KindImage }
+func (n *FootnoteRef) Kind() Kind   { return KindFootnoteRef }
+
+// tree manipulation
+
+// AppendChild adds n as the last child of parent.
+// n is removed from its current position first.
+func AppendChild(parent, n Node) {
+	Remove(n)
+	p, b := parent.base(), n.base()
+	b.parent = parent
+	if p.last == nil {
+		p.first = n
+	} else {
+		b.prev = p.last
+		p.last.base().next = n
+	}
+	p.last = n
+}
+
+// InsertBefore adds n as the previous sibling of ref, which must have a parent
+func InsertBefore(ref, n Node) {
+	Remove(n)
+	r, b := ref.base(), n.base()
+	b.parent, b.prev, b.next = r.parent, r.prev, ref
+	if r.prev == nil {
+		r.parent.base().first = n
+	} else {
+		r.prev.base().next = n
+	}
+	r.prev = n
+}
+
+// InsertAfter adds n as the next sibling of ref, which must have a parent
+func InsertAfter(ref, n Node) {
+	Remove(n)
+	r, b := ref.base(), n.base()
+	b.parent, b.prev, b.next = r.parent, ref, r.next
+	if r.next == nil {
+		r.parent.base().last = n
+	} else {
+		r.next.base().prev = n
+	}
+	r.next = n
+}
+
+// Remove detaches n and its children from the tree
+func Remove(n Node) {
+	b := n.base()
+	if b.parent == nil {
+		return
+	}
+	p := b.parent.base()
+	if b.prev == nil {
+		p.first = b.next
+	} else {
+		b.prev.base().next = b.next
+	}
+	if b.next == nil {
+		p.last = b.prev
+	} else {
+		b.next.base().prev = b.prev
+	}
+	b.parent, b.prev, b.next = nil, nil, nil
+}
+
+// Replace puts n at the position of old and detaches old
+func Replace(old, n Node) {
+	if old == n {
+		return
+	}
+	InsertBefore(old, n)
+	Remove(old)
+}
+
+// Children returns the children of n
+func Children(n Node) []Node {
+	ret := make([]Node, 0)
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		ret = append(ret, c)
+	}
+	return ret
+}
@@ -0,0 +1,139 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+func text(s string) *Text {
+	return &Text{Value: []byte(s)}
+}
+
+// dump returns the kinds and texts of the tree in document order
+func dump(n Node) string {
+	var b strings.Builder
+	Walk(n, func(n Node, entering bool) WalkStatus {
+		switch {
+		case n.Kind() == KindText:
+			if entering {
+				b.WriteString(string(n.(*Text).Value))
+			}
+		case entering:
+			b.WriteString(n.Kind().String() + "(")
+		default:
+			b.WriteString(")")
+		}
+		return WalkContinue
+	})
+	return b.String()
+}
+
+func TestTree(t *testing.T) {
+	doc := &Document{}
+	p := &Paragraph{}
+	a, b, c := text("a"), text("b"), text("c")
+	AppendChild(doc, p)
+	AppendChild(p, b)
+	InsertBefore(b, a)
+	InsertAfter(b, c)
+
+	if expected, actual := "Document(Paragraph(abc))", dump(doc); expected != actual {
+		t.Errorf("expected %v, but got %v\n", expected, actual)
+	}
+	if a.Parent() != p || b.PrevSibling() != a || b.NextSibling() != c || p.FirstChild() != a || p.LastChild() != c {
+		t.Errorf("unexpected links\n")
+	}
+
+	em := &Emphasis{}
+	Replace(b, em)
+	AppendChild(em, b)
+	if expected, actual := "Document(Paragraph(aEmphasis(b)c))", dump(doc); expected != actual {
+		t.Errorf("expected %v, but got %v\n", expected, actual)
+	}
+
+	Remove(a)
+	Remove(c)
+	if expected, actual := "Document(Paragraph(Emphasis(b)))", dump(doc); expected != actual {
+		t.Errorf("expected %v, but got %v\n", expected, actual)
+	}
+	if a.Parent() != nil || a.NextSibling() != nil || em.PrevSibling() != nil || em.NextSibling() != nil {
+		t.Errorf("unexpected links\n")
+	}
+
+	// moving a node detaches it first
+	AppendChild(doc, em)
+	if expected, actual := "Document(Paragraph()Emphasis(b))", dump(doc); expected != actual {
+		t.Errorf("expected %v, but got %v\n", expected, actual)
+	}
+	if len(Children(doc)) != 2 || len(Children(p)) != 0 {
+		t.Errorf("unexpected children\n")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	doc := &Document{}
+	for _, s := range []string{"a", "b", "c"} {
+		p := &Paragraph{}
+		AppendChild(p, text(s))
+		AppendChild(doc, p)
+	}
+
+	testcases := []struct {
+		status   func(n Node) WalkStatus
+		expected string
+	}{
+		{func(n Node) WalkStatus { return WalkContinue }, "Document(Paragraph(a)Paragraph(b)Paragraph(c))"},
+		{func(n Node) WalkStatus {
+			if n.Kind() == KindParagraph {
+				return WalkSkipChildren
+			}
+			return WalkContinue
+		}, "Document(Paragraph()Paragraph()Paragraph())"},
+		{func(n Node) WalkStatus {
+			if t, ok := n.(*Text); ok && string(t.Value) == "b" {
+				return WalkStop
+			}
+			return WalkContinue
+		}, "Document(Paragraph(a)Paragraph(b"},
+	}
+
+	for _, tt := range testcases {
+		var b strings.Builder
+		Walk(doc, func(n Node, entering bool) WalkStatus {
+			switch {
+			case n.Kind() == KindText:
+				if entering {
+					b.WriteString(string(n.(*Text).Value))
+				}
+			case entering:
+				b.WriteString(n.Kind().String() + "(")
+			default:
+				b.WriteString(")")
+			}
+			if !entering {
+				return WalkContinue
+			}
+			return tt.status(n)
+		})
+		if actual := b.String(); tt.expected != actual {
+			t.Errorf("expected %v, but got %v\n", tt.expected, actual)
+		}
+	}
+}
+
+func TestWalkRemove(t *testing.T) {
+	doc := &Document{}
+	for _, s := range []string{"a", "b", "c"} {
+		AppendChild(doc, text(s))
+	}
+
+	Walk(doc, func(n Node, entering bool) WalkStatus {
+		if t, ok := n.(*Text); ok && entering && string(t.Value) != "b" {
+			Remove(n)
+		}
+		return WalkContinue
+	})
+	if expected, actual := "Document(b)", dump(doc); expected != actual {
+		t.Errorf("expected %v, but got %v\n", expected, actual)
+	}
+}
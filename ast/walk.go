@@ -0,0 +1,36 @@
+package ast
+
+// WalkStatus tells Walk how to continue
+type WalkStatus int
+
+const (
+	// WalkContinue visits the children and siblings of the node
+	WalkContinue WalkStatus = iota
+	// WalkSkipChildren does not visit the children of the node
+	WalkSkipChildren
+	// WalkStop ends the walk
+	WalkStop
+)
+
+// Walk visits n and its descendants in document order. fn is called when
+// entering a node and again when leaving it after its children.
+// The visited node may be removed or replaced while walking.
+func Walk(n Node, fn func(n Node, entering bool) WalkStatus) WalkStatus {
+	status := fn(n, true)
+	if status == WalkStop {
+		return WalkStop
+	}
+	if status != WalkSkipChildren {
+		for c := n.FirstChild(); c != nil; {
+			next := c.NextSibling()
+			if Walk(c, fn) == WalkStop {
+				return WalkStop
+			}
+			c = next
+		}
+	}
+	if fn(n, false) == WalkStop {
+		return WalkStop
+	}
+	return WalkContinue
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/matsuyoshi30/gom2h"
+)
+
+// batch
+//
+// With -outdir the arguments are files, directories and glob patterns.
+// Directory trees are mirrored into the output directory: markdown files
+// are converted and other files copied alongside. Hidden files and
+// directories are skipped. Relative links to markdown files are rewritten
+// to the html files.
+
+type batchJob struct {
+	src  string
+	dir  string
+	root string // the path from dir to the output directory
+	copy bool
+}
+
+// runBatch converts the files of args into outdir with jobs workers and
+// reports a summary. Only the files in only are converted, if given.
+func runBatch(cfg *config, args []string, outdir string, jobs int, only map[string]bool) int {
+	if cfg.format == "term" {
+		fmt.Fprintln(os.Stderr, "cannot use -format term with -outdir")
+		return exitNG
+	}
+	list, err := batchJobs(args, outdir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
+		return exitNG
+	}
+	if only != nil {
+		filtered := make([]batchJob, 0)
+		for _, job := range list {
+			if only[job.src] {
+				filtered = append(filtered, job)
+			}
+		}
+		list = filtered
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+	if cfg.format == "html" {
+		// links between the converted files
+		c := *cfg
+		c.opts = append(append([]gom2h.Option(nil), cfg.opts...), mdLinks(htmlHref))
+		cfg = &c
+	}
+
+	var mu sync.Mutex
+	var converted, copied, failed int
+	queue := make(chan batchJob)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				ok := runJob(cfg, job)
+				mu.Lock()
+				switch {
+				case !ok:
+					failed++
+				case job.copy:
+					copied++
+				default:
+					converted++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, job := range list {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	fmt.Fprintf(os.Stdout, "%d converted, %d copied, %d failed\n", converted, copied, failed)
+	if failed > 0 {
+		return exitNG
+	}
+	return exitOK
+}
+
+// batchJobs lists the files of args with their output directories
+func batchJobs(args []string, outdir string) ([]batchJob, error) {
+	out, err := filepath.Abs(outdir)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]batchJob, 0)
+	for _, arg := range args {
+		paths := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			paths, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, err
+			}
+			if len(paths) == 0 {
+				return nil, fmt.Errorf("no files match %s", arg)
+			}
+		}
+
+		for _, root := range paths {
+			info, err := os.Stat(root)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				list = append(list, batchJob{src: root, dir: outdir})
+				continue
+			}
+
+			err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if path != root && strings.HasPrefix(info.Name(), ".") {
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if info.IsDir() {
+					// the output may be inside the tree
+					if abs, _ := filepath.Abs(path); outdir != "" && abs == out {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				rel, err := filepath.Rel(root, path)
+				if err != nil {
+					return err
+				}
+				job := batchJob{src: path, dir: filepath.Join(outdir, filepath.Dir(rel)), copy: !isMarkdown(path)}
+				if d := filepath.ToSlash(filepath.Dir(rel)); d != "." {
+					job.root = strings.Repeat("../", strings.Count(d, "/")+1)
+				}
+				list = append(list, job)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// a.md and a.markdown would both be written to a.html
+	outputs := make(map[string]string)
+	for _, job := range list {
+		if job.copy {
+			continue
+		}
+		name := filepath.Join(job.dir, outputName(job.src, ""))
+		if src, ok := outputs[name]; ok && filepath.Clean(src) != filepath.Clean(job.src) {
+			return nil, fmt.Errorf("%s and %s are converted to the same file", src, job.src)
+		}
+		outputs[name] = job.src
+	}
+	return list, nil
+}
+
+func runJob(cfg *config, job batchJob) bool {
+	if err := os.MkdirAll(job.dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
+		return false
+	}
+	if job.copy {
+		dst := filepath.Join(job.dir, filepath.Base(job.src))
+		if err := copyFile(job.src, dst); err != nil {
+			fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
+			return false
+		}
+		if cfg.written != nil {
+			cfg.written(job.src, dst)
+		}
+		return true
+	}
+	// relURL links from the directory of the page
+	c := *cfg
+	c.root = job.root
+	return convertFile(&c, job.src, "", job.dir) == exitOK
+}
+
+func isMarkdown(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".md" || ext == ".markdown"
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
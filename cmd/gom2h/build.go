@@ -0,0 +1,537 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/matsuyoshi30/gom2h"
+)
+
+// site
+//
+// gom2h build renders the markdown files of a source directory into a
+// static site. The config file is YAML (gom2h.yaml) or TOML (gom2h.toml)
+// like front matter:
+//
+//	title: Handbook          # site title
+//	baseURL: https://example.org/docs/
+//	source: docs             # default docs
+//	output: site             # default site
+//	layout: layout.tmpl      # default built-in layout
+//	layouts: layouts         # template directory, instead of layout
+//	css: style.css           # default built-in css
+//	anchors: true
+//
+// Every page is rendered through the layout, or the front matter layout of
+// the layouts directory, with a sidebar navigation of the directory tree.
+// Pages and directories are ordered by the front matter weight of the page
+// or the index.md of the directory, then by title. Directories without
+// index.md get a generated index page listing their pages, and sitemap.xml
+// lists all pages when baseURL is set.
+
+type siteConfig struct {
+	title   string
+	baseURL string
+	source  string
+	output  string
+	layout  string
+	layouts string
+	css     string
+	anchors bool
+}
+
+type sitePage struct {
+	src     string
+	path    string // output path relative to the site, with slashes
+	title   string
+	weight  int
+	meta    map[string]interface{}
+	content []byte
+	toc     []byte
+	words   int
+	modTime time.Time
+}
+
+type siteDir struct {
+	path   string // relative to the site with slashes, empty for the root
+	title  string
+	weight int
+	index  *sitePage
+	pages  []*sitePage
+	dirs   []*siteDir
+}
+
+type site struct {
+	config  siteConfig
+	tmpl    *template.Template
+	layouts *layouts
+	style   []byte
+	built   time.Time
+	root    *siteDir
+	dirs    map[string]*siteDir
+	pages   []*sitePage
+	assets  []string
+}
+
+// runBuild builds the site of the config file
+func runBuild(args []string) int {
+	fs := flag.NewFlagSet(name+" build", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stdout, "usage: %s build [flags]\n", name)
+		fs.PrintDefaults()
+	}
+
+	var configfile string
+	fs.StringVar(&configfile, "config", "", "path to config file (default gom2h.yaml or gom2h.toml)")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitNG
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitNG
+	}
+
+	config, err := readSiteConfig(configfile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitNG
+	}
+	if config.layout != "" && config.layouts != "" {
+		fmt.Fprintln(os.Stderr, "cannot use layout with layouts")
+		return exitNG
+	}
+	s, err := newSite(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitNG
+	}
+	if err := s.build(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitNG
+	}
+
+	fmt.Fprintf(os.Stdout, "%d pages, %d copied\n", len(s.pages), len(s.assets))
+	return exitOK
+}
+
+// readSiteConfig reads the config file with paths relative to it
+func readSiteConfig(configfile string) (siteConfig, error) {
+	config := siteConfig{source: "docs", output: "site"}
+	if configfile == "" {
+		for _, name := range []string{"gom2h.yaml", "gom2h.yml", "gom2h.toml"} {
+			if _, err := os.Stat(name); err == nil {
+				configfile = name
+				break
+			}
+		}
+	}
+
+	meta := make(map[string]interface{})
+	if configfile != "" {
+		b, err := ioutil.ReadFile(configfile)
+		if err != nil {
+			return config, err
+		}
+
+		// the config is parsed as the front matter of an empty document
+		delim := "---\n"
+		if filepath.Ext(configfile) == ".toml" {
+			delim = "+++\n"
+		}
+		res, err := gom2h.Convert([]byte(delim + string(b) + "\n" + delim))
+		if perr, ok := err.(*gom2h.ParseError); ok {
+			return config, fmt.Errorf("%s:%d:%d: %s", configfile, perr.Line-1, perr.Col, perr.Msg)
+		} else if err != nil {
+			return config, err
+		}
+		if res.Meta != nil {
+			meta = res.Meta
+		}
+	}
+
+	str := func(key string, v *string) {
+		if s, ok := meta[key]; ok && s != nil {
+			*v = fmt.Sprint(s)
+		}
+	}
+	str("title", &config.title)
+	str("baseURL", &config.baseURL)
+	str("source", &config.source)
+	str("output", &config.output)
+	str("layout", &config.layout)
+	str("layouts", &config.layouts)
+	str("css", &config.css)
+	if v, ok := meta["anchors"].(bool); ok {
+		config.anchors = v
+	}
+
+	dir := filepath.Dir(configfile)
+	for _, p := range []*string{&config.source, &config.output, &config.layout, &config.layouts, &config.css} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	return config, nil
+}
+
+func newSite(config siteConfig) (*site, error) {
+	s := &site{config: config, dirs: make(map[string]*siteDir), built: time.Now()}
+
+	layout := siteLayout
+	if config.layout != "" {
+		b, err := ioutil.ReadFile(config.layout)
+		if err != nil {
+			return nil, fmt.Errorf("could not read layout: %v", err)
+		}
+		layout = string(b)
+	}
+	tmpl, err := template.New("layout").Funcs(pageFuncs("")).Parse(layout)
+	if err != nil {
+		return nil, err
+	}
+	s.tmpl = tmpl
+	if config.layouts != "" {
+		if s.layouts, err = readLayouts(config.layouts); err != nil {
+			return nil, err
+		}
+	}
+
+	s.style = css()
+	if config.css != "" {
+		s.style, err = ioutil.ReadFile(config.css)
+		if err != nil {
+			return nil, fmt.Errorf("could not read css: %v", err)
+		}
+	}
+	return s, nil
+}
+
+// build reads the source tree and writes the site
+func (s *site) build() error {
+	if err := s.read(); err != nil {
+		return err
+	}
+	s.sort(s.root)
+
+	for _, p := range s.pages {
+		if err := s.writePage(p); err != nil {
+			return err
+		}
+	}
+	for _, rel := range s.assets {
+		dst := filepath.Join(s.config.output, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := copyFile(filepath.Join(s.config.source, rel), dst); err != nil {
+			return err
+		}
+	}
+	return s.writeSitemap()
+}
+
+// read converts the markdown files of the source tree and adds index
+// pages to the directories without one
+func (s *site) read() error {
+	// directories are added with their pages
+	s.dir("")
+	out, _ := filepath.Abs(s.config.output)
+	err := filepath.Walk(s.config.source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != s.config.source && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(s.config.source, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if info.IsDir() {
+			if abs, _ := filepath.Abs(path); abs == out {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isMarkdown(path) {
+			s.assets = append(s.assets, rel)
+			return nil
+		}
+		return s.readPage(path, rel, info.ModTime())
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.root.title == "" {
+		s.root.title = s.config.title
+	}
+	for _, d := range s.dirs {
+		if d.index == nil {
+			d.index = &sitePage{path: pathJoin(d.path, "index.html"), title: d.title, weight: d.weight}
+			s.pages = append(s.pages, d.index)
+		}
+	}
+	return nil
+}
+
+func (s *site) readPage(path, rel string, modTime time.Time) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	opts := []gom2h.Option{mdLinks(htmlHref)}
+	if s.config.anchors {
+		opts = append(opts, gom2h.WithHeadingAnchors())
+	}
+	res, err := gom2h.Convert(b, opts...)
+	if perr, ok := err.(*gom2h.ParseError); ok {
+		return fmt.Errorf("%s:%v", path, perr)
+	} else if err != nil {
+		return err
+	}
+
+	base := strings.TrimSuffix(rel, filepath.Ext(rel))
+	p := &sitePage{
+		src:     path,
+		path:    base + ".html",
+		title:   strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		meta:    res.Meta,
+		content: res.HTML,
+		toc:     gom2h.TOC(res.Headings),
+		words:   res.WordCount,
+		modTime: modTime,
+	}
+	if v, ok := res.Meta["title"]; ok && v != nil {
+		p.title = fmt.Sprint(v)
+	} else if len(res.Headings) > 0 {
+		p.title = res.Headings[0].Text
+	}
+	p.weight = weight(res.Meta)
+	s.pages = append(s.pages, p)
+
+	d := s.dir(dirOf(rel))
+	if filepath.Base(base) == "index" {
+		d.index = p
+		d.title = p.title
+		d.weight = p.weight
+	} else {
+		d.pages = append(d.pages, p)
+	}
+	return nil
+}
+
+// dir returns the directory rel of the tree, adding it and its parents
+func (s *site) dir(rel string) *siteDir {
+	if rel == "." {
+		rel = ""
+	}
+	if d, ok := s.dirs[rel]; ok {
+		return d
+	}
+	d := &siteDir{path: rel, title: filepath.Base(rel)}
+	s.dirs[rel] = d
+	if rel == "" {
+		d.title = ""
+		s.root = d
+	} else {
+		parent := s.dir(dirOf(rel))
+		parent.dirs = append(parent.dirs, d)
+	}
+	return d
+}
+
+// sort orders the pages and directories of d by weight and title
+func (s *site) sort(d *siteDir) {
+	sort.SliceStable(d.pages, func(i, j int) bool {
+		a, b := d.pages[i], d.pages[j]
+		if a.weight != b.weight {
+			return a.weight < b.weight
+		}
+		return a.title < b.title
+	})
+	sort.SliceStable(d.dirs, func(i, j int) bool {
+		a, b := d.dirs[i], d.dirs[j]
+		if a.weight != b.weight {
+			return a.weight < b.weight
+		}
+		return a.title < b.title
+	})
+	for _, sub := range d.dirs {
+		s.sort(sub)
+	}
+}
+
+func (s *site) writePage(p *sitePage) error {
+	content := p.content
+	if p.src == "" {
+		content = s.listing(s.dirs[dirOf(p.path)], p.path)
+	}
+
+	title := p.title
+	if s.config.title != "" && title != s.config.title {
+		title = p.title + " - " + s.config.title
+	}
+	page := Page{
+		Title:      title,
+		Meta:       p.meta,
+		Stylesheet: template.CSS(s.style),
+		Content:    template.HTML(content),
+		TOC:        template.HTML(p.toc),
+		Source:     p.src,
+		ModTime:    p.modTime,
+		WordCount:  p.words,
+		BuildTime:  s.built,
+		Nav:        template.HTML(s.nav(s.root, p.path)),
+		Site:       s.config.title,
+		Root:       relPath(p.path, "index.html"),
+	}
+
+	// relURL links from the directory of the page
+	var tmpl *template.Template
+	var err error
+	if s.layouts != nil {
+		tmpl, err = s.layouts.layout(metaLayout(p.meta))
+	} else {
+		tmpl, err = s.tmpl.Clone()
+	}
+	if err != nil {
+		return err
+	}
+	tmpl.Funcs(pageFuncs(strings.Repeat("../", strings.Count(p.path, "/"))))
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, page); err != nil {
+		return err
+	}
+	dst := filepath.Join(s.config.output, filepath.FromSlash(p.path))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, buf.Bytes(), 0644)
+}
+
+// nav returns the navigation of the pages and directories of d with
+// links relative to the page from
+func (s *site) nav(d *siteDir, from string) string {
+	if len(d.pages) == 0 && len(d.dirs) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("<ul>\n")
+	for _, p := range d.pages {
+		b.WriteString(navLink(p, from) + "</li>\n")
+	}
+	for _, sub := range d.dirs {
+		b.WriteString(navLink(sub.index, from))
+		if list := s.nav(sub, from); list != "" {
+			b.WriteString("\n" + list)
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ul>\n")
+	return b.String()
+}
+
+func navLink(p *sitePage, from string) string {
+	class := ""
+	if p.path == from {
+		class = ` class="active"`
+	}
+	return fmt.Sprintf(`<li%s><a href="%s">%s</a>`, class, html.EscapeString(relPath(from, p.path)), html.EscapeString(p.title))
+}
+
+// listing returns the content of the generated index page of d
+func (s *site) listing(d *siteDir, from string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<h1>%s</h1>\n<ul>\n", html.EscapeString(d.title))
+	for _, p := range d.pages {
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(relPath(from, p.path)), html.EscapeString(p.title))
+	}
+	for _, sub := range d.dirs {
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(relPath(from, sub.index.path)), html.EscapeString(sub.title))
+	}
+	b.WriteString("</ul>")
+	return []byte(b.String())
+}
+
+// writeSitemap lists the pages under the base URL. Sitemaps need absolute
+// URLs, so there is none without a base URL.
+func (s *site) writeSitemap() error {
+	if s.config.baseURL == "" {
+		fmt.Fprintln(os.Stderr, "warning: no baseURL, sitemap.xml is not written")
+		return nil
+	}
+	base := strings.TrimSuffix(s.config.baseURL, "/") + "/"
+	var b strings.Builder
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	b.WriteString("<urlset xmlns=\"http://www.sitemaps.org/schemas/sitemap/0.9\">\n")
+	pages := append([]*sitePage(nil), s.pages...)
+	sort.Slice(pages, func(i, j int) bool { return pages[i].path < pages[j].path })
+	for _, p := range pages {
+		loc := base + p.path
+		if p.path == "index.html" || strings.HasSuffix(p.path, "/index.html") {
+			loc = strings.TrimSuffix(loc, "index.html")
+		}
+		fmt.Fprintf(&b, "  <url>\n    <loc>%s</loc>\n", html.EscapeString(loc))
+		if !p.modTime.IsZero() {
+			fmt.Fprintf(&b, "    <lastmod>%s</lastmod>\n", p.modTime.UTC().Format("2006-01-02"))
+		}
+		b.WriteString("  </url>\n")
+	}
+	b.WriteString("</urlset>\n")
+	return ioutil.WriteFile(filepath.Join(s.config.output, "sitemap.xml"), []byte(b.String()), 0644)
+}
+
+// weight returns the front matter weight, 0 when not set
+func weight(meta map[string]interface{}) int {
+	switch v := meta["weight"].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// dirOf returns the directory of the slash separated path rel
+func dirOf(rel string) string {
+	if i := strings.LastIndex(rel, "/"); i >= 0 {
+		return rel[:i]
+	}
+	return ""
+}
+
+func pathJoin(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
+
+// relPath returns the link from the page from to the page to
+func relPath(from, to string) string {
+	rel, err := filepath.Rel(filepath.FromSlash("./"+dirOf(from)), filepath.FromSlash(to))
+	if err != nil {
+		return to
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// diffContext is the number of unchanged lines around a change
+const diffContext = 3
+
+type edit struct {
+	op   byte // ' ', '-' or '+'
+	line []byte
+}
+
+// unifiedDiff returns the line diff from a to b in unified format
+func unifiedDiff(filename string, a, b []byte) []byte {
+	edits := diffLines(splitLines(a), splitLines(b))
+
+	// line numbers in a and b before each edit
+	as, bs := make([]int, len(edits)+1), make([]int, len(edits)+1)
+	for i, e := range edits {
+		as[i+1], bs[i+1] = as[i], bs[i]
+		if e.op != '+' {
+			as[i+1]++
+		}
+		if e.op != '-' {
+			bs[i+1]++
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s.orig\n+++ %s\n", filename, filename)
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
+		}
+
+		// merge changes separated by few unchanged lines into one hunk
+		start := max(i-diffContext, 0)
+		end := i
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+			j := end
+			for j < len(edits) && edits[j].op == ' ' {
+				j++
+			}
+			if j == len(edits) || j-end > 2*diffContext {
+				break
+			}
+			end = j
+		}
+		end = min(end+diffContext, len(edits))
+
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(as[start], as[end]), hunkRange(bs[start], bs[end]))
+		for _, e := range edits[start:end] {
+			buf.WriteByte(e.op)
+			buf.Write(e.line)
+			buf.WriteByte('\n')
+		}
+		i = end
+	}
+	return buf.Bytes()
+}
+
+func hunkRange(start, end int) string {
+	if end-start == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	if end == start {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end-start)
+}
+
+func splitLines(b []byte) [][]byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return bytes.Split(bytes.TrimSuffix(b, []byte("\n")), []byte("\n"))
+}
+
+// diffLines returns the edits from a to b along a longest common subsequence
+func diffLines(a, b [][]byte) []edit {
+	// lcs[i][j] is the length of the lcs of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if bytes.Equal(a[i], b[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	edits := make([]edit, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case bytes.Equal(a[i], b[j]):
+			edits = append(edits, edit{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		edits = append(edits, edit{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		edits = append(edits, edit{'+', b[j]})
+	}
+	return edits
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
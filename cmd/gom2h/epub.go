@@ -0,0 +1,410 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"hash"
+	"html"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/matsuyoshi30/gom2h"
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// epub
+//
+// The markdown files are the chapters of an EPUB 3 book. Each chapter is
+// an XHTML content document, the nav document lists the headings of all
+// chapters and local images are put into the book. The front matter keys
+// book, author and lang of the first chapter set the book metadata.
+
+var (
+	// bare ampersands and named entities which XML does not know
+	entityExp = regexp.MustCompile(`&[#0-9A-Za-z]*;?`)
+	// a less-than sign which does not start a tag
+	bareLtExp = regexp.MustCompile(`<([^A-Za-z/!?]|$)`)
+	// void elements of raw html which are not closed
+	voidExp = regexp.MustCompile(`(?i)<(area|base|br|col|embed|hr|img|input|link|meta|param|source|track|wbr)\b([^>]*?)\s*/?>`)
+)
+
+type epubChapter struct {
+	ID       string
+	Href     string
+	Title    string
+	Content  string
+	Remote   bool
+	Headings []gom2h.Heading
+}
+
+type epubItem struct {
+	ID        string
+	Href      string
+	MediaType string
+	src       string
+}
+
+// epubFile is a file of the book written from a template
+type epubFile struct {
+	name string
+	tmpl string
+	data interface{}
+}
+
+type epubBook struct {
+	Title    string
+	Author   string
+	Lang     string
+	ID       string
+	Modified string
+	Nav      string
+	Chapters []*epubChapter
+	Images   []*epubItem
+
+	meta     map[string]interface{}
+	images   map[string]*epubItem
+	hrefs    map[string]string
+	modified time.Time
+	hash     hash.Hash
+}
+
+func newEPUB() *epubBook {
+	return &epubBook{images: make(map[string]*epubItem), hrefs: make(map[string]string), hash: sha1.New()}
+}
+
+// addChapter converts the markdown file filename with content b into the
+// next chapter of the book
+func (book *epubBook) addChapter(filename string, b []byte, opts []gom2h.Option) error {
+	ch := &epubChapter{ID: fmt.Sprintf("chapter%d", len(book.Chapters)+1)}
+	ch.Href = book.chapterHref(filename)
+
+	// links to the other chapters
+	dir := filepath.Dir(filename)
+	opts = append(opts, mdLinks(func(p string) (string, bool) {
+		href, ok := book.hrefs[filepath.Join(dir, filepath.FromSlash(p))]
+		return href, ok
+	}))
+
+	var images []*ast.Image
+	opts = append(opts, gom2h.WithTransformer(gom2h.TransformerFunc(func(doc *ast.Document) error {
+		ast.Walk(doc, func(n ast.Node, entering bool) ast.WalkStatus {
+			if img, ok := n.(*ast.Image); ok && entering {
+				images = append(images, img)
+			}
+			return ast.WalkContinue
+		})
+		for _, img := range images {
+			if gom2h.IsAbsURL(string(img.Dest)) {
+				ch.Remote = true
+				continue
+			}
+			item, err := book.addImage(dir, string(img.Dest))
+			if err != nil {
+				return err
+			}
+			img.Dest = []byte(item.Href)
+		}
+		return nil
+	})))
+	res, err := gom2h.Convert(b, opts...)
+	if err != nil {
+		return err
+	}
+
+	ch.Title = fileTitle(filename)
+	if v, ok := res.Meta["title"]; ok && v != nil {
+		ch.Title = fmt.Sprint(v)
+	} else if len(res.Headings) > 0 {
+		ch.Title = res.Headings[0].Text
+	}
+	ch.Content = string(xhtml(res.HTML))
+	ch.Headings = res.Headings
+
+	if book.meta == nil {
+		book.meta = res.Meta
+		if book.meta == nil {
+			book.meta = make(map[string]interface{})
+		}
+	}
+	if filename != "-" {
+		if info, err := os.Stat(filename); err == nil && info.ModTime().After(book.modified) {
+			book.modified = info.ModTime()
+		}
+	}
+	book.hash.Write(b)
+	book.Chapters = append(book.Chapters, ch)
+	return nil
+}
+
+// chapterHref returns the name of the content document of filename,
+// unique in the book
+func (book *epubBook) chapterHref(filename string) string {
+	filename = filepath.Clean(filename)
+	if href, ok := book.hrefs[filename]; ok {
+		return href
+	}
+	base := fileTitle(filename)
+	if filename == "-" {
+		base = "stdin"
+	}
+	href := base + ".xhtml"
+	for i := 2; book.taken(href); i++ {
+		href = fmt.Sprintf("%s-%d.xhtml", base, i)
+	}
+	book.hrefs[filename] = href
+	return href
+}
+
+func (book *epubBook) taken(href string) bool {
+	for _, h := range book.hrefs {
+		if h == href {
+			return true
+		}
+	}
+	for _, img := range book.Images {
+		if img.Href == href {
+			return true
+		}
+	}
+	return false
+}
+
+// addImage adds the image dest relative to dir to the book once
+func (book *epubBook) addImage(dir, dest string) (*epubItem, error) {
+	if p, err := url.PathUnescape(dest); err == nil {
+		dest = p
+	}
+	src := filepath.Join(dir, filepath.FromSlash(dest))
+	if item, ok := book.images[src]; ok {
+		return item, nil
+	}
+	if _, err := os.Stat(src); err != nil {
+		return nil, fmt.Errorf("could not read image: %v", err)
+	}
+
+	ext := filepath.Ext(src)
+	base := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '#' || r == '?' || r == '%' {
+			return '-'
+		}
+		return r
+	}, strings.TrimSuffix(filepath.Base(src), ext))
+	href := "images/" + base + ext
+	for i := 2; book.taken(href); i++ {
+		href = fmt.Sprintf("images/%s-%d%s", base, i, ext)
+	}
+	mediaType := mime.TypeByExtension(strings.ToLower(ext))
+	if mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+
+	item := &epubItem{ID: fmt.Sprintf("image%d", len(book.Images)+1), Href: href, MediaType: mediaType, src: src}
+	book.images[src] = item
+	book.Images = append(book.Images, item)
+	return item, nil
+}
+
+// write writes the book named title with the stylesheet style to out
+func (book *epubBook) write(out io.Writer, title string, style []byte) error {
+	meta := func(key, def string) string {
+		if v, ok := book.meta[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return def
+	}
+	book.Title = meta("book", title)
+	book.Author = meta("author", "")
+	book.Lang = meta("lang", "en")
+	sum := fmt.Sprintf("%x", book.hash.Sum(nil))
+	book.ID = fmt.Sprintf("urn:uuid:%s-%s-5%s-8%s-%s", sum[0:8], sum[8:12], sum[13:16], sum[17:20], sum[20:32])
+	if book.modified.IsZero() {
+		book.modified = time.Now()
+	}
+	book.modified = book.modified.UTC().Truncate(time.Second)
+	book.Modified = book.modified.Format("2006-01-02T15:04:05Z")
+	book.Nav = book.nav()
+
+	zw := zip.NewWriter(out)
+
+	// the mimetype comes first, uncompressed and without extra fields
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	io.WriteString(w, "application/epub+zip")
+
+	files := []epubFile{
+		{"META-INF/container.xml", epubContainer, book},
+		{"OEBPS/content.opf", epubPackage, book},
+		{"OEBPS/nav.xhtml", epubNav, book},
+	}
+	for _, ch := range book.Chapters {
+		files = append(files, epubFile{"OEBPS/" + ch.Href, epubContent, struct {
+			*epubBook
+			Chapter *epubChapter
+		}{book, ch}})
+	}
+	for _, file := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: book.modified})
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(file.name).Funcs(template.FuncMap{"xml": html.EscapeString}).Parse(file.tmpl)
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(w, file.data); err != nil {
+			return err
+		}
+	}
+
+	w, err = zw.CreateHeader(&zip.FileHeader{Name: "OEBPS/style.css", Method: zip.Deflate, Modified: book.modified})
+	if err != nil {
+		return err
+	}
+	w.Write(style)
+	for _, img := range book.Images {
+		b, err := ioutil.ReadFile(img.src)
+		if err != nil {
+			return fmt.Errorf("could not read image: %v", err)
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: "OEBPS/" + img.Href, Method: zip.Deflate, Modified: book.modified})
+		if err != nil {
+			return err
+		}
+		w.Write(b)
+	}
+
+	return zw.Close()
+}
+
+type navPoint struct {
+	Level int
+	Text  string
+	Href  string
+}
+
+// nav returns the nested lists of the headings of all chapters. A chapter
+// without headings is listed with its title.
+func (book *epubBook) nav() string {
+	points := make([]navPoint, 0)
+	for _, ch := range book.Chapters {
+		if len(ch.Headings) == 0 {
+			points = append(points, navPoint{Level: 1, Text: ch.Title, Href: ch.Href})
+			continue
+		}
+		for _, h := range ch.Headings {
+			points = append(points, navPoint{Level: h.Level, Text: h.Text, Href: ch.Href + "#" + h.ID})
+		}
+	}
+
+	var b strings.Builder
+	navList(&b, points, 0, 0)
+	return b.String()
+}
+
+// navList writes the points from i deeper than level as a list and
+// returns the index of the first point not written
+func navList(b *strings.Builder, points []navPoint, i, level int) int {
+	b.WriteString("<ol>\n")
+	for i < len(points) && points[i].Level > level {
+		p := points[i]
+		fmt.Fprintf(b, `<li><a href="%s">%s</a>`, html.EscapeString(p.Href), html.EscapeString(p.Text))
+		i++
+		if i < len(points) && points[i].Level > p.Level {
+			b.WriteString("\n")
+			i = navList(b, points, i, p.Level)
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ol>")
+	if level > 0 {
+		b.WriteString("\n")
+	}
+	return i
+}
+
+// xhtml makes converted html well-formed XML: bare ampersands and
+// less-than signs are escaped, named entities replaced by their
+// characters and void elements closed
+func xhtml(b []byte) []byte {
+	b = entityExp.ReplaceAllFunc(b, func(m []byte) []byte {
+		s := string(m)
+		switch {
+		case s == "&amp;", s == "&lt;", s == "&gt;", s == "&quot;", s == "&apos;":
+			return m
+		case strings.HasPrefix(s, "&#") && strings.HasSuffix(s, ";") && len(s) > 3:
+			return m
+		case strings.HasSuffix(s, ";") && html.UnescapeString(s) != s:
+			return []byte(html.EscapeString(html.UnescapeString(s)))
+		}
+		return []byte("&amp;" + s[1:])
+	})
+	b = bareLtExp.ReplaceAll(b, []byte("&lt;$1"))
+	return voidExp.ReplaceAll(b, []byte("<$1$2 />"))
+}
+
+// runEPUB writes the markdown files as the chapters of the book out, of a
+// book named after the first chapter, or to stdout for - and for a book
+// read from stdin
+func runEPUB(files []string, out, cssfile string, opts []gom2h.Option) int {
+	book := newEPUB()
+	inputs := make([][]byte, len(files))
+	for i, filename := range files {
+		b, err := readInput(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
+			return exitNG
+		}
+		inputs[i] = b
+		// chapters link to the following chapters as well
+		book.chapterHref(filename)
+	}
+	if out == "" {
+		out = outputName(files[0], ".epub")
+		if files[0] == "-" {
+			out = "-"
+		}
+	}
+	for i, filename := range files {
+		if err := book.addChapter(filename, inputs[i], opts); err != nil {
+			name := filename
+			if filename == "-" {
+				name = "stdin"
+			}
+			return convertError(name, err)
+		}
+	}
+
+	style := css()
+	if cssfile != "" {
+		var err error
+		style, err = ioutil.ReadFile(cssfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not read css: %v\n", err)
+			return exitNG
+		}
+	}
+
+	// the book is named after its file, or its first chapter on stdout
+	title := fileTitle(out)
+	if title == "" {
+		title = book.Chapters[0].Title
+	}
+	var buf bytes.Buffer
+	if err := book.write(&buf, title, style); err != nil {
+		fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
+		return exitNG
+	}
+	return writeOutput(out, "", out, buf.Bytes())
+}
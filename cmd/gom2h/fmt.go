@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/matsuyoshi30/gom2h"
+)
+
+// runFmt formats markdown files like gofmt
+func runFmt(args []string) int {
+	fs := flag.NewFlagSet(name+" fmt", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stdout, "usage: %s fmt [flags] [markdown files]\n", name)
+		fs.PrintDefaults()
+	}
+
+	var write bool
+	var diff bool
+	var width int
+	var softBreaks bool
+	fs.BoolVar(&write, "w", false, "write result to the source file instead of stdout")
+	fs.BoolVar(&diff, "d", false, "display diffs instead of rewriting files")
+	fs.IntVar(&width, "wrap", 0, "wrap paragraphs with soft breaks at this column, they are unwrapped without")
+	fs.BoolVar(&softBreaks, "soft-breaks", false, "join consecutive lines into one paragraph like the converter's -soft-breaks")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitNG
+	}
+
+	var opts []gom2h.Option
+	if softBreaks {
+		opts = append(opts, gom2h.WithSoftBreaks())
+	}
+	if width > 0 {
+		opts = append(opts, gom2h.WithWrap(width))
+	}
+
+	if fs.NArg() == 0 {
+		if write {
+			fmt.Fprintln(os.Stderr, "cannot use -w with standard input")
+			return exitNG
+		}
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
+			return exitNG
+		}
+		if err := formatFile("<standard input>", b, opts, false, diff); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitNG
+		}
+		return exitOK
+	}
+
+	ret := exitOK
+	for _, filename := range fs.Args() {
+		b, err := ioutil.ReadFile(filename)
+		if err == nil {
+			err = formatFile(filename, b, opts, write, diff)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			ret = exitNG
+		}
+	}
+	return ret
+}
+
+// formatFile writes the formatted markdown of filename to stdout, the file
+// itself or as a diff to stdout
+func formatFile(filename string, b []byte, opts []gom2h.Option, write, diff bool) error {
+	out, err := gom2h.Format(b, opts...)
+	if perr, ok := err.(*gom2h.ParseError); ok {
+		return fmt.Errorf("%s:%v", filename, perr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !write && !diff {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	if bytes.Equal(b, out) {
+		return nil
+	}
+	if write {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filename, out, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if diff {
+		_, err = os.Stdout.Write(unifiedDiff(filename, b, out))
+	}
+	return err
+}
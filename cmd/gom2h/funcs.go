@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/matsuyoshi30/gom2h"
+)
+
+// template functions
+//
+// The templates of -tmpl and gom2h build can use
+//
+//	{{ date "Jan 2, 2006" .ModTime }}   formats a time or a front matter date
+//	{{ markdown .Meta.summary }}        renders markdown, a paragraph inline
+//	{{ relURL "/css/site.css" }}        links to a path of the site from the page
+
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// pageFuncs returns the functions for a page at root, the path from the
+// page to the root of the site
+func pageFuncs(root string) template.FuncMap {
+	return template.FuncMap{
+		"date":     formatDate,
+		"markdown": markdownHTML,
+		"relURL": func(target string) string {
+			return relURL(root, target)
+		},
+	}
+}
+
+func formatDate(layout string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case time.Time:
+		return v.Format(layout), nil
+	case string:
+		for _, l := range dateLayouts {
+			if t, err := time.Parse(l, v); err == nil {
+				return t.Format(layout), nil
+			}
+		}
+		return "", fmt.Errorf("date: cannot parse %q", v)
+	}
+	return "", fmt.Errorf("date: unexpected %T", v)
+}
+
+func markdownHTML(v interface{}) (template.HTML, error) {
+	if v == nil {
+		return "", nil
+	}
+	out, err := gom2h.Run([]byte(fmt.Sprint(v)))
+	if err != nil {
+		return "", err
+	}
+	if bytes.HasPrefix(out, []byte("<p>")) && bytes.HasSuffix(out, []byte("</p>")) && bytes.Count(out, []byte("<p>")) == 1 {
+		out = out[len("<p>") : len(out)-len("</p>")]
+	}
+	return template.HTML(out), nil
+}
+
+func relURL(root, target string) string {
+	if gom2h.IsAbsURL(target) || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "//") {
+		return target
+	}
+	target = strings.TrimPrefix(target, "/")
+	if root+target == "" {
+		return "./"
+	}
+	return root + target
+}
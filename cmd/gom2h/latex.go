@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"text/template"
+
+	"github.com/matsuyoshi30/gom2h"
+)
+
+type LaTeXPage struct {
+	Title   string
+	Meta    map[string]interface{}
+	Content string
+}
+
+// latexDocument puts the LaTeX body of filename into the template tmplfile,
+// the template of the front matter or the default standalone document
+func latexDocument(filename, tmplfile string, meta map[string]interface{}, body []byte) ([]byte, error) {
+	if v, ok := meta["template"].(string); ok {
+		tmplfile = filepath.Join(filepath.Dir(filename), v)
+	}
+	tmplstr := latexIndex
+	if tmplfile != "" {
+		b, err := ioutil.ReadFile(tmplfile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read template file: %v", err)
+		}
+		tmplstr = string(b)
+	}
+	tmpl, err := template.New("latex").Parse(tmplstr)
+	if err != nil {
+		return nil, err
+	}
+
+	title := fileTitle(filename)
+	if v, ok := meta["title"]; ok && v != nil {
+		title = fmt.Sprint(v)
+	}
+	page := LaTeXPage{
+		Title:   gom2h.EscapeLaTeX(title),
+		Meta:    meta,
+		Content: string(body),
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, page); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// layouts
+//
+// -layouts dir/ reads a template directory instead of a single -tmpl file:
+//
+//	dir/base.tmpl            the page skeleton with {{ block "content" . }} and others
+//	dir/partials/header.tmpl partials, {{ template "header" . }} by file name
+//	dir/page.tmpl            the default layout, overriding the blocks of base
+//	dir/<name>.tmpl          other layouts, selected with front matter layout: <name>
+//
+// A layout is rendered through base when there is one, and on its own
+// otherwise. Several doc sets can share a base by sharing the directory.
+
+const (
+	layoutBase    = "base.tmpl"
+	layoutDefault = "page"
+)
+
+type layouts struct {
+	fsys fs.FS
+	// base and the partials, cloned for each layout
+	base    *template.Template
+	hasBase bool
+
+	mu sync.Mutex
+	// the parsed layouts by name, cloned for each page
+	parsed map[string]*template.Template
+}
+
+// readLayouts parses the base and the partials of the directory dir
+func readLayouts(dir string) (*layouts, error) {
+	fsys := os.DirFS(dir)
+	l := &layouts{fsys: fsys, parsed: make(map[string]*template.Template)}
+
+	var patterns []string
+	if _, err := fs.Stat(fsys, layoutBase); err == nil {
+		patterns = append(patterns, layoutBase)
+		l.hasBase = true
+	}
+	partials, err := fs.Glob(fsys, "partials/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	if len(partials) > 0 {
+		patterns = append(patterns, "partials/*.tmpl")
+	}
+
+	l.base = template.New(layoutBase).Funcs(pageFuncs(""))
+	if len(patterns) == 0 {
+		return l, nil
+	}
+	if l.base, err = l.base.ParseFS(fsys, patterns...); err != nil {
+		return nil, fmt.Errorf("could not parse layouts: %v", err)
+	}
+	// partials are named after their files
+	for _, p := range partials {
+		name := strings.TrimSuffix(path.Base(p), ".tmpl")
+		if l.base.Lookup(name) != nil {
+			continue
+		}
+		if _, err := l.base.AddParseTree(name, l.base.Lookup(path.Base(p)).Tree); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// parseLayouts parses the -layouts of cfg once for all pages, and again
+// when they change. Layouts which do not parse are read for each page,
+// which reports the error.
+func (cfg *config) parseLayouts() {
+	cfg.layoutSet = nil
+	if cfg.layouts != "" {
+		cfg.layoutSet, _ = readLayouts(cfg.layouts)
+	}
+}
+
+// isLayout reports whether the file f is in the -layouts directory of cfg
+func (cfg *config) isLayout(f string) bool {
+	return cfg.layouts != "" && strings.HasPrefix(f, filepath.Clean(cfg.layouts)+string(filepath.Separator))
+}
+
+// layout returns the template of the layout name, the default layout when
+// name is empty
+func (l *layouts) layout(name string) (*template.Template, error) {
+	file := layoutDefault + ".tmpl"
+	if name != "" {
+		file = name + ".tmpl"
+		if path.Base(file) != file || file == layoutBase {
+			return nil, fmt.Errorf("invalid layout: %s", name)
+		}
+	}
+
+	l.mu.Lock()
+	parsed, ok := l.parsed[name]
+	if !ok {
+		var err error
+		if parsed, err = l.parse(file, name == ""); err != nil {
+			l.mu.Unlock()
+			return nil, err
+		}
+		l.parsed[name] = parsed
+	}
+	l.mu.Unlock()
+
+	// pages set their own funcs
+	tmpl, err := parsed.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if l.hasBase {
+		return tmpl.Lookup(layoutBase), nil
+	}
+	return tmpl.Lookup(file), nil
+}
+
+// parse parses the layout file with the base and the partials. The
+// default layout may be missing when there is a base.
+func (l *layouts) parse(file string, def bool) (*template.Template, error) {
+	tmpl, err := l.base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fs.Stat(l.fsys, file); err == nil {
+		if tmpl, err = tmpl.ParseFS(l.fsys, file); err != nil {
+			return nil, fmt.Errorf("could not parse layout: %v", err)
+		}
+	} else if !def || !l.hasBase {
+		return nil, fmt.Errorf("no layout %s", strings.TrimSuffix(file, ".tmpl"))
+	}
+	return tmpl, nil
+}
+
+// metaLayout returns the front matter layout of meta
+func metaLayout(meta map[string]interface{}) string {
+	if v, ok := meta["layout"]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/matsuyoshi30/gom2h"
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// mdLinks resolves relative links to markdown files with href, which
+// returns the link to the file generated from a markdown file. Queries
+// and fragments are kept.
+func mdLinks(href func(p string) (string, bool)) gom2h.Option {
+	return gom2h.WithURLResolver(func(n ast.Node, dest string) string {
+		if n.Kind() != ast.KindLink || gom2h.IsAbsURL(dest) || strings.HasPrefix(dest, "/") {
+			return dest
+		}
+		p, rest := dest, ""
+		if i := strings.IndexAny(dest, "?#"); i >= 0 {
+			p, rest = dest[:i], dest[i:]
+		}
+		if !isMarkdown(p) {
+			return dest
+		}
+		if h, ok := href(p); ok {
+			return h + rest
+		}
+		return dest
+	})
+}
+
+// htmlHref links to the html file next to the markdown file p
+func htmlHref(p string) (string, bool) {
+	return strings.TrimSuffix(p, filepath.Ext(p)) + ".html", true
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
 	"flag"
 	"fmt"
 	"html/template"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/matsuyoshi30/gom2h"
+	"github.com/matsuyoshi30/gom2h/ast"
 )
 
 const name = "gom2h"
@@ -24,22 +29,90 @@ const (
 )
 
 type Page struct {
+	Title      string
+	Meta       map[string]interface{}
 	Stylesheet template.CSS
 	Content    template.HTML
+	TOC        template.HTML
+	// Source is the path of the markdown file, ModTime its modification
+	// time and WordCount the number of words of its text
+	Source    string
+	ModTime   time.Time
+	WordCount int
+	// BuildTime is the time of the conversion
+	BuildTime time.Time
+	// Nav, Site and Root are set by gom2h build: the navigation, the
+	// site title and the link to the site root
+	Nav  template.HTML
+	Site string
+	Root string
+}
+
+// config is the conversion of files set by the flags
+type config struct {
+	cssfile  string
+	tmplfile string
+	layouts  string
+	format   string
+	noCode   bool
+	truncate int
+	noColor  bool
+	width    int
+	opts     []gom2h.Option
+	// the time of the conversion, now when not set
+	buildTime time.Time
+	// the path from the page to the root of -outdir, for relURL
+	root string
+	// the parsed -layouts, nil when they do not parse
+	layoutSet *layouts
+	// called with the source and output of each written file, for -watch
+	written func(src, output string)
 }
 
 func run(args []string) int {
+	if len(args) > 0 && args[0] == "fmt" {
+		return runFmt(args[1:])
+	}
+	if len(args) > 0 && args[0] == "build" {
+		return runBuild(args[1:])
+	}
+	if len(args) > 0 && args[0] == "serve" {
+		return runServe(args[1:])
+	}
+
 	fs := flag.NewFlagSet(name, flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
 	fs.Usage = func() {
-		fmt.Fprintf(os.Stdout, "usage: %s <markdown file>\n", name)
+		fmt.Fprintf(os.Stdout, "usage: %s [flags] <markdown file or - for stdin>\n", name)
+		fmt.Fprintf(os.Stdout, "       %s fmt [flags] [markdown files]\n", name)
+		fmt.Fprintf(os.Stdout, "       %s build [-config file]\n", name)
+		fmt.Fprintf(os.Stdout, "       %s serve [flags] [dir]\n", name)
 		flag.PrintDefaults()
 	}
 
-	var cssfile string
-	var tmplfile string
-	fs.StringVar(&cssfile, "css", "", "path to css file")
-	fs.StringVar(&tmplfile, "tmpl", "", "path to template file")
+	var cfg config
+	var anchors bool
+	var strict bool
+	var softBreaks bool
+	var output string
+	var outdir string
+	var jobs int
+	var watch bool
+	fs.StringVar(&cfg.cssfile, "css", "", "path to css file")
+	fs.StringVar(&cfg.tmplfile, "tmpl", "", "path to template file")
+	fs.StringVar(&cfg.layouts, "layouts", "", "path to template directory with base, layouts and partials")
+	fs.BoolVar(&anchors, "anchors", false, "add anchor links to headings")
+	fs.BoolVar(&strict, "strict", false, "report unclosed code fences, undefined footnotes and invalid front matter")
+	fs.BoolVar(&softBreaks, "soft-breaks", false, "join consecutive lines into one paragraph")
+	fs.StringVar(&cfg.format, "format", "html", "output format: html, text, json, term, man, latex or epub")
+	fs.BoolVar(&cfg.noCode, "no-code", false, "drop code blocks from text output")
+	fs.IntVar(&cfg.truncate, "truncate", 0, "truncate text output to this many characters")
+	fs.BoolVar(&cfg.noColor, "no-color", false, "write term output without colors")
+	fs.IntVar(&cfg.width, "width", termWidth(), "wrap term output at this column")
+	fs.StringVar(&output, "o", "", "path to output file, - for stdout")
+	fs.StringVar(&outdir, "outdir", "", "convert files and directory trees into this directory")
+	fs.IntVar(&jobs, "j", runtime.NumCPU(), "number of files converted at once with -outdir")
+	fs.BoolVar(&watch, "watch", false, "convert again when the files, -css, -tmpl or their front matter css or template change")
 	if err := fs.Parse(args); err != nil {
 		if err == flag.ErrHelp {
 			return exitOK
@@ -48,74 +121,351 @@ func run(args []string) int {
 	}
 	args = fs.Args()
 
-	if len(args) != 1 {
+	// the chapters of an epub are given in order
+	if len(args) == 0 || len(args) > 1 && cfg.format != "epub" && outdir == "" {
 		fs.Usage()
 		return exitNG
 	}
-	filename := args[0]
 
-	if filepath.Ext(filename) != ".md" && filepath.Ext(filename) != ".markdown" {
+	switch cfg.format {
+	case "html", "text", "json", "term", "man", "latex", "epub":
+	default:
 		fs.Usage()
 		return exitNG
 	}
 
-	wd, _ := os.Getwd()
-	b, err := ioutil.ReadFile(filepath.Join(wd, args[0]))
+	// run gom2h
+	cfg.buildTime = time.Now()
+	if anchors {
+		cfg.opts = append(cfg.opts, gom2h.WithHeadingAnchors())
+	}
+	if strict {
+		cfg.opts = append(cfg.opts, gom2h.WithStrict())
+	}
+	if softBreaks {
+		cfg.opts = append(cfg.opts, gom2h.WithSoftBreaks())
+	}
+	if outdir != "" && output != "" {
+		fmt.Fprintln(os.Stderr, "cannot use -o with -outdir")
+		return exitNG
+	}
+	if cfg.tmplfile != "" && cfg.layouts != "" {
+		fmt.Fprintln(os.Stderr, "cannot use -tmpl with -layouts")
+		return exitNG
+	}
+	if cfg.format == "epub" && outdir != "" {
+		fmt.Fprintln(os.Stderr, "cannot use -format epub with -outdir")
+		return exitNG
+	}
+	cfg.parseLayouts()
+	if watch {
+		return runWatch(&cfg, args, output, outdir, jobs)
+	}
+	if cfg.format == "epub" {
+		return runEPUB(args, output, cfg.cssfile, cfg.opts)
+	}
+	if outdir != "" {
+		return runBatch(&cfg, args, outdir, jobs, nil)
+	}
+	return convertFile(&cfg, args[0], output, "")
+}
+
+// convertFile converts the markdown file filename to output, or to a file
+// in dir named after filename when no output is given
+func convertFile(cfg *config, filename, output, dir string) int {
+	opts := append([]gom2h.Option(nil), cfg.opts...)
+	b, err := readInput(filename)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
 		return exitNG
 	}
+	// name is the file shown in errors
+	name := filename
+	if filename == "-" {
+		// stdin is written to stdout unless -o is given
+		name = "stdin"
+		if output == "" {
+			output = "-"
+		}
+	}
+
+	if cfg.format != "html" {
+		var out []byte
+		var ext, def string
+		switch cfg.format {
+		case "text":
+			if cfg.noCode {
+				opts = append(opts, gom2h.WithoutCodeBlocks())
+			}
+			if cfg.truncate > 0 {
+				opts = append(opts, gom2h.WithTruncate(cfg.truncate))
+			}
+			out, err = gom2h.PlainText(b, opts...)
+			ext = ".txt"
+		case "json":
+			out, err = gom2h.JSON(b, opts...)
+			ext = ".json"
+		case "term":
+			if cfg.noColor {
+				opts = append(opts, gom2h.WithoutColor())
+			}
+			if cfg.width > 0 {
+				opts = append(opts, gom2h.WithWrap(cfg.width))
+			}
+			out, err = gom2h.Terminal(b, opts...)
+		case "man":
+			// tool.1.md is the page of tool in section 1
+			page := fileTitle(filename)
+			section := strings.TrimPrefix(filepath.Ext(page), ".")
+			if section == "" || section[0] < '0' || section[0] > '9' {
+				section = "1"
+			} else {
+				page = strings.TrimSuffix(page, filepath.Ext(page))
+			}
+			opts = append(opts, gom2h.WithTransformer(manPage(page, &section)))
+			out, err = gom2h.Man(b, opts...)
+			ext = "." + section
+			def = page + ext
+		case "latex":
+			var meta map[string]interface{}
+			opts = append(opts, gom2h.WithTransformer(gom2h.TransformerFunc(func(doc *ast.Document) error {
+				meta = doc.Meta
+				return nil
+			})))
+			out, err = gom2h.LaTeX(b, opts...)
+			if err == nil {
+				out, err = latexDocument(filename, cfg.tmplfile, meta, out)
+			}
+			ext = ".tex"
+		}
+		if err != nil {
+			return convertError(name, err)
+		}
+		if cfg.format == "term" && output == "" {
+			// read in the terminal, or a pager with -no-color
+			output = "-"
+		}
+		if !bytes.HasSuffix(out, []byte("\n")) {
+			out = append(out, '\n')
+		}
+		if def == "" {
+			def = outputName(filename, ext)
+		}
+		return cfg.writeOutput(output, filename, filepath.Join(dir, def), out)
+	}
+
+	out, err := htmlPage(cfg, filename, b, opts)
+	if _, ok := err.(*gom2h.ParseError); ok {
+		return convertError(name, err)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitNG
+	}
+	return cfg.writeOutput(output, filename, filepath.Join(dir, outputName(filename, ".html")), out)
+}
+
+// htmlPage converts the markdown b of filename to a page of the -tmpl or
+// front matter template with the -css or front matter stylesheet
+func htmlPage(cfg *config, filename string, b []byte, opts []gom2h.Option) ([]byte, error) {
+	res, err := gom2h.Convert(b, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	cssfile, tmplfile := pageFiles(cfg, filename, res.Meta)
 
 	// read css
 	var style []byte
 	if cssfile != "" {
-		style, err = ioutil.ReadFile(filepath.Join(wd, cssfile))
+		style, err = ioutil.ReadFile(cssfile)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "could not read css: %v\n", err)
-			return exitNG
+			return nil, fmt.Errorf("could not read css: %v", err)
 		}
 	} else {
 		style = css()
 	}
 
-	// run gom2h
-	out, err := gom2h.Run(b)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
-		return exitNG
+	// the title is the front matter title, the first h1 or the file name
+	title := fileTitle(filename)
+	if v, ok := res.Meta["title"]; ok && v != nil {
+		title = fmt.Sprint(v)
+	} else if len(res.Headings) > 0 && res.Headings[0].Level == 1 {
+		title = res.Headings[0].Text
 	}
 
-	page := Page{Stylesheet: template.CSS(style), Content: template.HTML(out)}
+	page := Page{
+		Title:      title,
+		Meta:       res.Meta,
+		Stylesheet: template.CSS(style),
+		Content:    template.HTML(res.HTML),
+		TOC:        template.HTML(gom2h.TOC(res.Headings)),
+		WordCount:  res.WordCount,
+		BuildTime:  cfg.buildTime,
+	}
+	if filename != "-" {
+		page.Source = filename
+		if info, err := os.Stat(filename); err == nil {
+			page.ModTime = info.ModTime()
+		}
+	}
+	if page.BuildTime.IsZero() {
+		page.BuildTime = time.Now()
+	}
 
-	var tmplstr string
-	if tmplfile != "" {
-		b, err := ioutil.ReadFile(filepath.Join(wd, tmplfile))
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "could not read template file: %v\n", err)
-			return exitNG
+	var tmpl *template.Template
+	if cfg.layouts != "" && tmplfile == cfg.tmplfile {
+		// front matter layout selects the layout of the directory
+		l := cfg.layoutSet
+		if l == nil {
+			// report why the layouts do not parse
+			if l, err = readLayouts(cfg.layouts); err != nil {
+				return nil, err
+			}
+		}
+		if tmpl, err = l.layout(metaLayout(res.Meta)); err != nil {
+			return nil, err
 		}
-		tmplstr = string(b)
+		tmpl.Funcs(pageFuncs(cfg.root))
 	} else {
-		tmplstr = index
+		var tmplstr string
+		if tmplfile != "" {
+			b, err := ioutil.ReadFile(tmplfile)
+			if err != nil {
+				return nil, fmt.Errorf("could not read template file: %v", err)
+			}
+			tmplstr = string(b)
+		} else {
+			tmplstr = index
+		}
+		tmpl, err = template.New("tmplstr").Funcs(pageFuncs(cfg.root)).Parse(tmplstr)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected error: %v", err)
+		}
 	}
-	tmpl, err := template.New("tmplstr").Parse(tmplstr)
-	if err != nil {
+
+	// output html
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, page); err != nil {
+		return nil, fmt.Errorf("unexpected error: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// readInput reads the file filename, or stdin for -
+func readInput(filename string) ([]byte, error) {
+	if filename == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(filename)
+}
+
+// writeOutput writes out to the file output, to stdout for - or to def
+// when no output is given. The input file is never overwritten.
+func writeOutput(output, input, def string, out []byte) int {
+	if output == "" {
+		output = def
+	}
+	if output == "-" {
+		os.Stdout.Write(out)
+		return exitOK
+	}
+	if sameFile(output, input) {
+		fmt.Fprintf(os.Stderr, "output file %s is the input file\n", output)
+		return exitNG
+	}
+	if err := ioutil.WriteFile(output, out, 0644); err != nil {
 		fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
 		return exitNG
 	}
+	return exitOK
+}
+
+// writeOutput writes out with writeOutput and reports the written file to
+// cfg.written
+func (cfg *config) writeOutput(output, input, def string, out []byte) int {
+	code := writeOutput(output, input, def, out)
+	if code == exitOK && output != "-" && cfg.written != nil {
+		if output == "" {
+			output = def
+		}
+		cfg.written(input, output)
+	}
+	return code
+}
 
-	// output html
-	outFile, err := os.Create(filepath.Base(filename[:len(filename)-len(filepath.Ext(filename))]) + ".html")
+func sameFile(a, b string) bool {
+	ia, err := os.Stat(a)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
-		return exitNG
+		return false
+	}
+	ib, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ia, ib)
+}
+
+// outputName returns the name of the output file with ext for filename
+// in the current directory
+func outputName(filename, ext string) string {
+	return filepath.Base(filename[:len(filename)-len(filepath.Ext(filename))]) + ext
+}
+
+// pageFiles returns the stylesheet and template of the markdown file
+// filename: -css and -tmpl, or the front matter css and template relative
+// to the markdown file
+func pageFiles(cfg *config, filename string, meta map[string]interface{}) (cssfile, tmplfile string) {
+	cssfile, tmplfile = cfg.cssfile, cfg.tmplfile
+	if v, ok := meta["css"].(string); ok {
+		cssfile = filepath.Join(filepath.Dir(filename), v)
+	}
+	if v, ok := meta["template"].(string); ok {
+		tmplfile = filepath.Join(filepath.Dir(filename), v)
+	}
+	return cssfile, tmplfile
+}
+
+// fileTitle returns the name of filename without extension, the title of
+// documents without one. stdin has no name.
+func fileTitle(filename string) string {
+	if filename == "-" {
+		return ""
 	}
-	writer := bufio.NewWriter(outFile)
-	if err = tmpl.Execute(writer, page); err != nil {
+	return filepath.Base(filename[:len(filename)-len(filepath.Ext(filename))])
+}
+
+func convertError(filename string, err error) int {
+	if perr, ok := err.(*gom2h.ParseError); ok {
+		fmt.Fprintf(os.Stderr, "%s:%v\n", filename, perr)
+	} else {
 		fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
-		return exitNG
 	}
-	writer.Flush()
+	return exitNG
+}
 
-	return exitOK
+// termWidth returns the width of the terminal from $COLUMNS, or 80
+func termWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 80
+}
+
+// manPage sets the title and section of a man page when the front matter
+// does not, and reports the section
+func manPage(title string, section *string) gom2h.Transformer {
+	return gom2h.TransformerFunc(func(doc *ast.Document) error {
+		if doc.Meta == nil {
+			doc.Meta = make(map[string]interface{})
+		}
+		if _, ok := doc.Meta["title"]; !ok {
+			doc.Meta["title"] = title
+		}
+		if v, ok := doc.Meta["section"]; ok && v != nil {
+			*section = fmt.Sprint(v)
+		} else {
+			doc.Meta["section"] = *section
+		}
+		return nil
+	})
 }
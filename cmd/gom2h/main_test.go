@@ -1,12 +1,23 @@
 package main
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/matsuyoshi30/gom2h"
 )
 
 func TestMain(t *testing.T) {
@@ -19,12 +30,17 @@ func TestMain(t *testing.T) {
 				t.Errorf("FAIL: command execution")
 			}
 
-			outFile := strings.TrimSuffix(path, filepath.Ext(path)) + ".html"
+			// the expected file has the extension of the output file
+			base := strings.TrimSuffix(path, filepath.Ext(path))
+			expFile := base + "_exp.html"
+			if m, _ := filepath.Glob(base + "_exp.*"); len(m) == 1 {
+				expFile = m[0]
+			}
+			outFile := base + strings.TrimPrefix(expFile, base+"_exp")
 			output, err := ioutil.ReadFile(outFile)
 			if err != nil {
 				t.Errorf("FAIL: Reading on output file: %s\n", outFile)
 			}
-			expFile := strings.TrimSuffix(path, filepath.Ext(path)) + "_exp.html"
 			expected, err := ioutil.ReadFile(expFile)
 			if err != nil {
 				t.Errorf("FAIL: Reading on expected file: %s\n", expFile)
@@ -43,3 +59,619 @@ func TestMain(t *testing.T) {
 		return nil
 	})
 }
+
+func TestDiff(t *testing.T) {
+	testcases := []struct {
+		a, b     string
+		expected string
+	}{
+		{"a\nb\nc\n", "a\nB\nc\n", "--- f.md.orig\n+++ f.md\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		{"", "a\n", "--- f.md.orig\n+++ f.md\n@@ -0,0 +1 @@\n+a\n"},
+		{"1\n2\n3\n4\n5\n6\n7\n8\n9\n", "0\n2\n3\n4\n5\n6\n7\n8\n0\n", "--- f.md.orig\n+++ f.md\n@@ -1,4 +1,4 @@\n-1\n+0\n 2\n 3\n 4\n@@ -6,4 +6,4 @@\n 6\n 7\n 8\n-9\n+0\n"},
+	}
+
+	for _, tt := range testcases {
+		actual := unifiedDiff("f.md", []byte(tt.a), []byte(tt.b))
+		if !bytes.Equal([]byte(tt.expected), actual) {
+			t.Errorf("expected %q, but got %q\n", tt.expected, actual)
+		}
+	}
+}
+
+func TestEPUB(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"ch1.md":       "---\nbook: Handbook\n---\n\n# Welcome\n\nTom & Jerry <b>a < b</b> ![logo](img/logo.png) [notes](ch2.md#top)\n\n## Setup\n\n<div>one<br>two&nbsp;</div>\n",
+		"ch2.md":       "notes\n",
+		"img/logo.png": "\x89PNG",
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := filepath.Join(dir, "book.epub")
+	if code := run([]string{"-format", "epub", "-o", out, filepath.Join(dir, "ch1.md"), filepath.Join(dir, "ch2.md")}); code != exitOK {
+		t.Fatalf("expected exit code %d, but got %d\n", exitOK, code)
+	}
+
+	zr, err := zip.OpenReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	if f := zr.File[0]; f.Name != "mimetype" || f.Method != zip.Store {
+		t.Errorf("expected stored mimetype first, but got %s (method %d)\n", f.Name, f.Method)
+	}
+	contents := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		contents[f.Name] = string(b)
+
+		// all documents are well-formed XML
+		if strings.HasSuffix(f.Name, ".xhtml") || strings.HasSuffix(f.Name, ".opf") || strings.HasSuffix(f.Name, ".xml") {
+			d := xml.NewDecoder(bytes.NewReader(b))
+			for {
+				if _, err := d.Token(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Errorf("%s: %v\n", f.Name, err)
+					break
+				}
+			}
+		}
+	}
+
+	for name, expected := range map[string]string{
+		"mimetype":              "application/epub+zip",
+		"OEBPS/content.opf":     `<dc:title>Handbook</dc:title>`,
+		"OEBPS/nav.xhtml":       `<li><a href="ch1.xhtml#setup">Setup</a></li>`,
+		"OEBPS/ch1.xhtml":       `<img src="images/logo.png" alt="logo" /> <a href="ch2.xhtml#top">notes</a>`,
+		"OEBPS/ch2.xhtml":       `<p>notes</p>`,
+		"OEBPS/images/logo.png": "\x89PNG",
+	} {
+		if !strings.Contains(contents[name], expected) {
+			t.Errorf("expected %s to contain %q, but got %q\n", name, expected, contents[name])
+		}
+	}
+	if !strings.Contains(contents["OEBPS/nav.xhtml"], `<li><a href="ch2.xhtml">ch2</a></li>`) {
+		t.Errorf("expected a chapter without headings in the nav, but got %q\n", contents["OEBPS/nav.xhtml"])
+	}
+}
+
+func TestBatch(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"docs/index.md":           "# Index\n",
+		"docs/guide/intro.md":     "# Intro\n",
+		"docs/guide/img/logo.png": "\x89PNG",
+		"docs/.hidden/notes.md":   "# Hidden\n",
+		"extra.markdown":          "# Extra\n",
+	} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outdir := filepath.Join(dir, "docs", "site")
+	args := []string{"-j", "2", "-format", "text", "-outdir", outdir, filepath.Join(dir, "docs"), filepath.Join(dir, "*.markdown")}
+	if code := run(args); code != exitOK {
+		t.Fatalf("expected exit code %d, but got %d\n", exitOK, code)
+	}
+
+	for name, expected := range map[string]string{
+		"index.txt":          "Index\n",
+		"guide/intro.txt":    "Intro\n",
+		"guide/img/logo.png": "\x89PNG",
+		"extra.txt":          "Extra\n",
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(outdir, name))
+		if err != nil {
+			t.Errorf("expected %s, but got %v\n", name, err)
+		} else if string(b) != expected {
+			t.Errorf("expected %s to be %q, but got %q\n", name, expected, b)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outdir, ".hidden")); err == nil {
+		t.Errorf("expected hidden directories to be skipped\n")
+	}
+	if _, err := os.Stat(filepath.Join(outdir, "site")); err == nil {
+		t.Errorf("expected the output directory to be skipped\n")
+	}
+
+	// html links between the converted files
+	ioutil.WriteFile(filepath.Join(dir, "docs", "index.md"), []byte("See [intro](guide/intro.md#usage) and [site](https://example.org/a.md).\n"), 0644)
+	if code := run([]string{"-outdir", outdir, filepath.Join(dir, "docs", "index.md")}); code != exitOK {
+		t.Fatalf("expected exit code %d, but got %d\n", exitOK, code)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(outdir, "index.html"))
+	if expected := `<a href="guide/intro.html#usage">intro</a> and <a href="https://example.org/a.md">site</a>`; !strings.Contains(string(b), expected) {
+		t.Errorf("expected index.html to contain %q, but got %q\n", expected, b)
+	}
+
+	// the same output file
+	ioutil.WriteFile(filepath.Join(dir, "extra.md"), []byte("# Extra\n"), 0644)
+	if code := run([]string{"-outdir", outdir, filepath.Join(dir, "extra.md"), filepath.Join(dir, "extra.markdown")}); code != exitNG {
+		t.Errorf("expected exit code %d for colliding outputs, but got %d\n", exitNG, code)
+	}
+	if code := run([]string{"-format", "epub", "-outdir", outdir, filepath.Join(dir, "extra.md")}); code != exitNG {
+		t.Errorf("expected exit code %d for -format epub with -outdir, but got %d\n", exitNG, code)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"gom2h.yaml":            "title: Handbook\nbaseURL: https://example.org/docs/\n",
+		"docs/index.md":         "# Welcome\n\nStart with the [intro](guide/intro.md#setup).\n",
+		"docs/guide/intro.md":   "---\nweight: 1\n---\n# Intro\n",
+		"docs/guide/install.md": "---\nweight: 2\n---\n# Install\n",
+		"docs/api/client.md":    "# Client\n",
+		"docs/api/reindex.md":   "# Reindex\n",
+		"docs/api/img/logo.png": "\x89PNG",
+	} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if code := run([]string{"build", "-config", filepath.Join(dir, "gom2h.yaml")}); code != exitOK {
+		t.Fatalf("expected exit code %d, but got %d\n", exitOK, code)
+	}
+
+	site := filepath.Join(dir, "site")
+	for name, expected := range map[string]string{
+		"index.html":       `<a href="guide/intro.html#setup">intro</a>`,
+		"guide/index.html": `<li><a href="intro.html">Intro</a></li>` + "\n" + `<li><a href="install.html">Install</a></li>`,
+		"guide/intro.html": `<li class="active"><a href="intro.html">Intro</a></li>`,
+		"api/client.html":  `<a href="../index.html">Handbook</a>`,
+		"api/img/logo.png": "\x89PNG",
+		"sitemap.xml":      "<loc>https://example.org/docs/guide/intro.html</loc>",
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(site, name))
+		if err != nil {
+			t.Errorf("expected %s, but got %v\n", name, err)
+		} else if !strings.Contains(string(b), expected) {
+			t.Errorf("expected %s to contain %q, but got %q\n", name, expected, b)
+		}
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(site, "sitemap.xml"))
+	for _, expected := range []string{"<loc>https://example.org/docs/</loc>", "<loc>https://example.org/docs/guide/</loc>", "<loc>https://example.org/docs/api/reindex.html</loc>"} {
+		if !strings.Contains(string(b), expected) {
+			t.Errorf("expected sitemap.xml to contain %q, but got %q\n", expected, b)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(site, "api", "img", "index.html")); err == nil {
+		t.Errorf("expected no index page for a directory without pages\n")
+	}
+
+	// sitemaps need the base URL
+	config := filepath.Join(dir, "relative.yaml")
+	if err := ioutil.WriteFile(config, []byte("title: Handbook\noutput: relative\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := run([]string{"build", "-config", config}); code != exitOK {
+		t.Fatalf("expected exit code %d, but got %d\n", exitOK, code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "relative", "index.html")); err != nil {
+		t.Errorf("expected index.html, but got %v\n", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "relative", "sitemap.xml")); err == nil {
+		t.Errorf("expected no sitemap.xml without baseURL\n")
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := "{{ .Title }}|{{ .Source }}|{{ .WordCount }}|{{ date \"Jan 2, 2006\" .Meta.date }}|{{ markdown .Meta.summary }}|{{ relURL \"/css/site.css\" }}|{{ .BuildTime.IsZero }}"
+	for name, content := range map[string]string{
+		"page.tmpl": tmpl,
+		"page.md":   "---\ndate: 2021-03-04\nsummary: A *short* summary\n---\n\n# Guide\n\nThree more words\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src := filepath.Join(dir, "page.md")
+	out := filepath.Join(dir, "page.html")
+	if code := run([]string{"-tmpl", filepath.Join(dir, "page.tmpl"), "-o", out, src}); code != exitOK {
+		t.Fatalf("expected exit code %d, but got %d\n", exitOK, code)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Guide|" + src + "|4|Mar 4, 2021|A <em>short</em> summary|css/site.css|false"
+	if string(b) != expected {
+		t.Errorf("expected %q, but got %q\n", expected, b)
+	}
+
+	// relURL links from the directory of the page in -outdir
+	docs := filepath.Join(dir, "docs")
+	os.MkdirAll(filepath.Join(docs, "guide", "setup"), 0755)
+	for _, name := range []string{"index.md", "guide/intro.md", "guide/setup/linux.md"} {
+		if err := ioutil.WriteFile(filepath.Join(docs, name), []byte("# Page\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	site := filepath.Join(dir, "site")
+	if code := run([]string{"-tmpl", filepath.Join(dir, "page.tmpl"), "-outdir", site, docs}); code != exitOK {
+		t.Fatalf("expected exit code %d, but got %d\n", exitOK, code)
+	}
+	for name, expected := range map[string]string{
+		"index.html":             "|css/site.css|",
+		"guide/intro.html":       "|../css/site.css|",
+		"guide/setup/linux.html": "|../../css/site.css|",
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(site, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), expected) {
+			t.Errorf("expected %s to contain %q, but got %q\n", name, expected, b)
+		}
+	}
+
+	for _, tt := range []struct {
+		root, target, expected string
+	}{
+		{"", "/", "./"},
+		{"../", "/", "../"},
+		{"../../", "css/site.css", "../../css/site.css"},
+		{"../", "https://example.org/", "https://example.org/"},
+		{"../", "#top", "#top"},
+	} {
+		if actual := relURL(tt.root, tt.target); actual != tt.expected {
+			t.Errorf("relURL(%q, %q): expected %q, but got %q\n", tt.root, tt.target, tt.expected, actual)
+		}
+	}
+}
+
+func TestLayouts(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"layouts/base.tmpl":            `{{ template "header" . }}|{{ block "content" . }}{{ .Content }}{{ end }}|{{ block "footer" . }}base footer{{ end }}`,
+		"layouts/partials/header.tmpl": `<title>{{ .Title }}</title>`,
+		"layouts/page.tmpl":            `{{ define "content" }}<main>{{ .Content }}</main>{{ end }}`,
+		"layouts/post.tmpl":            `{{ define "footer" }}<a href="{{ relURL "/" }}">posts</a>{{ end }}`,
+		"docs/page.md":                 "# Page\n",
+		"docs/posts/post.md":           "---\nlayout: post\n---\n# Post\n",
+		"docs/other.md":                "---\nlayout: missing\n---\n# Other\n",
+	} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	layouts := filepath.Join(dir, "layouts")
+
+	for _, tt := range []struct {
+		src, expected string
+	}{
+		{"docs/page.md", "<title>Page</title>|<main><h1 id=\"page\">Page</h1></main>|base footer"},
+		{"docs/posts/post.md", "<title>Post</title>|<h1 id=\"post\">Post</h1>|<a href=\"./\">posts</a>"},
+	} {
+		out := filepath.Join(dir, "out.html")
+		if code := run([]string{"-layouts", layouts, "-o", out, filepath.Join(dir, tt.src)}); code != exitOK {
+			t.Fatalf("%s: expected exit code %d, but got %d\n", tt.src, exitOK, code)
+		}
+		b, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(b)) != tt.expected {
+			t.Errorf("%s: expected %q, but got %q\n", tt.src, tt.expected, b)
+		}
+	}
+	if code := run([]string{"-layouts", layouts, "-o", filepath.Join(dir, "out.html"), filepath.Join(dir, "docs/other.md")}); code != exitNG {
+		t.Errorf("expected exit code %d for a missing layout, but got %d\n", exitNG, code)
+	}
+	if code := run([]string{"-layouts", layouts, "-tmpl", filepath.Join(dir, "layouts/page.tmpl"), filepath.Join(dir, "docs/page.md")}); code != exitNG {
+		t.Errorf("expected exit code %d for -tmpl with -layouts, but got %d\n", exitNG, code)
+	}
+	if code := runServe([]string{"-layouts", layouts, "-tmpl", filepath.Join(dir, "layouts/page.tmpl"), dir}); code != exitNG {
+		t.Errorf("expected exit code %d for serve -tmpl with -layouts, but got %d\n", exitNG, code)
+	}
+
+	// the layouts are parsed once for all pages
+	cfg := &config{layouts: layouts}
+	cfg.parseLayouts()
+	page, err := htmlPage(cfg, filepath.Join(dir, "docs/page.md"), []byte("# Page\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Rename(layouts, layouts+".old")
+	again, err := htmlPage(cfg, filepath.Join(dir, "docs/page.md"), []byte("# Page\n"), nil)
+	os.Rename(layouts+".old", layouts)
+	if err != nil || !bytes.Equal(page, again) {
+		t.Errorf("expected %q from the parsed layouts, but got %q, %v\n", page, again, err)
+	}
+
+	// relURL links from the directory of the page in -outdir
+	site := filepath.Join(dir, "out")
+	os.Remove(filepath.Join(dir, "docs/other.md"))
+	if code := run([]string{"-layouts", layouts, "-outdir", site, filepath.Join(dir, "docs")}); code != exitOK {
+		t.Fatalf("expected exit code %d, but got %d\n", exitOK, code)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(site, "posts", "post.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `<a href="../">posts</a>`; !strings.Contains(string(b), expected) {
+		t.Errorf("expected post.html to contain %q, but got %q\n", expected, b)
+	}
+
+	// the site shares the layouts, relURL is relative to the page
+	config := filepath.Join(dir, "gom2h.yaml")
+	if err := ioutil.WriteFile(config, []byte("layouts: layouts\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := run([]string{"build", "-config", config}); code != exitOK {
+		t.Fatalf("expected exit code %d, but got %d\n", exitOK, code)
+	}
+	b, err = ioutil.ReadFile(filepath.Join(dir, "site", "posts", "post.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `<a href="../">posts</a>`; !strings.Contains(string(b), expected) {
+		t.Errorf("expected post.html to contain %q, but got %q\n", expected, b)
+	}
+
+	// a site has a layout or layouts
+	if err := ioutil.WriteFile(config, []byte("layouts: layouts\nlayout: layouts/page.tmpl\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := run([]string{"build", "-config", config}); code != exitNG {
+		t.Errorf("expected exit code %d for layout with layouts, but got %d\n", exitNG, code)
+	}
+}
+
+func TestWatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.md")
+	b := filepath.Join(dir, "sub", "b.md")
+	os.MkdirAll(filepath.Dir(b), 0755)
+	ioutil.WriteFile(a, []byte("# A\n"), 0644)
+	ioutil.WriteFile(b, []byte("# B\n"), 0644)
+
+	stop := make(chan struct{})
+	calls := make(chan []string, 10)
+	done := make(chan struct{})
+	go func() {
+		watchFiles([]string{dir}, "", 20*time.Millisecond, stop, func(changed []string) {
+			calls <- changed
+		})
+		close(done)
+	}()
+
+	// a burst of writes is reported once
+	time.Sleep(50 * time.Millisecond)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(a, later, later)
+	os.Chtimes(b, later, later)
+	time.Sleep(10 * time.Millisecond)
+	os.Chtimes(a, later.Add(time.Second), later.Add(time.Second))
+
+	select {
+	case changed := <-calls:
+		if expected := []string{a, b}; !reflect.DeepEqual(expected, changed) {
+			t.Errorf("expected %v, but got %v\n", expected, changed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected a change\n")
+	}
+
+	os.Remove(b)
+	select {
+	case changed := <-calls:
+		if expected := []string{b}; !reflect.DeepEqual(expected, changed) {
+			t.Errorf("expected %v, but got %v\n", expected, changed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected a change\n")
+	}
+
+	close(stop)
+	<-done
+	if len(calls) > 0 {
+		t.Errorf("unexpected changes %v\n", <-calls)
+	}
+}
+
+func TestRemoveOutputs(t *testing.T) {
+	dir := t.TempDir()
+	docs := filepath.Join(dir, "docs")
+	a := filepath.Join(docs, "a.md")
+	b := filepath.Join(docs, "b.md")
+	logo := filepath.Join(docs, "logo.png")
+	os.MkdirAll(docs, 0755)
+	ioutil.WriteFile(a, []byte("# A\n"), 0644)
+	ioutil.WriteFile(b, []byte("# B\n"), 0644)
+	ioutil.WriteFile(logo, []byte("\x89PNG"), 0644)
+
+	outputs := make(map[string]string)
+	cfg := &config{format: "html", written: func(src, output string) { outputs[src] = output }}
+	outdir := filepath.Join(dir, "site")
+	if code := runBatch(cfg, []string{docs}, outdir, 1, nil); code != exitOK {
+		t.Fatalf("expected exit code %d, but got %d\n", exitOK, code)
+	}
+
+	os.Remove(a)
+	os.Remove(logo)
+	removeOutputs(outputs, []string{a, b, logo})
+	for name, expected := range map[string]bool{"a.html": false, "b.html": true, "logo.png": false} {
+		if _, err := os.Stat(filepath.Join(outdir, name)); (err == nil) != expected {
+			t.Errorf("expected %s to exist %v, but got %v\n", name, expected, err)
+		}
+	}
+}
+
+func TestWatchMetaFiles(t *testing.T) {
+	dir := t.TempDir()
+	docs := filepath.Join(dir, "docs")
+	page := filepath.Join(docs, "page.md")
+	other := filepath.Join(docs, "other.md")
+	style := filepath.Join(dir, "style.css")
+	os.MkdirAll(docs, 0755)
+	ioutil.WriteFile(page, []byte("---\ncss: ../style.css\n---\n# Page\n"), 0644)
+	ioutil.WriteFile(other, []byte("# Other\n"), 0644)
+	ioutil.WriteFile(style, []byte("body {}\n"), 0644)
+
+	if expected, actual := []string{page}, dependents([]string{docs}, "", []string{style}); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v, but got %v\n", expected, actual)
+	}
+
+	stop := make(chan struct{})
+	calls := make(chan []string, 10)
+	done := make(chan struct{})
+	go func() {
+		watchFiles([]string{docs}, "", 20*time.Millisecond, stop, func(changed []string) {
+			calls <- changed
+		})
+		close(done)
+	}()
+
+	// the stylesheet of the front matter is outside the watched directory
+	time.Sleep(50 * time.Millisecond)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(style, later, later)
+	select {
+	case changed := <-calls:
+		if expected := []string{style}; !reflect.DeepEqual(expected, changed) {
+			t.Errorf("expected %v, but got %v\n", expected, changed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected a change\n")
+	}
+
+	close(stop)
+	<-done
+}
+
+func TestServe(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "guide"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "index.md"), []byte("# Home\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "guide", "intro.md"), []byte("# Intro\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "guide", "logo.png"), []byte("\x89PNG"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "broken.md"), []byte("[^1]\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, ".git", "config"), []byte("[core]\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "guide", ".env"), []byte("TOKEN=secret\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "styled.md"), []byte("---\ncss: style.css\n---\n# Styled\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "style.css"), []byte("h1 { color: red; }"), 0644)
+
+	s := newServer(&config{opts: []gom2h.Option{gom2h.WithStrict()}}, dir)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	testcases := []struct {
+		path     string
+		status   int
+		expected string
+	}{
+		{"/", http.StatusOK, `<h1 id="home">Home</h1>`},
+		{"/guide/intro.md", http.StatusOK, `<h1 id="intro">Intro</h1>`},
+		{"/guide/intro.html", http.StatusOK, reloadScript + "\n</body>"},
+		{"/guide", http.StatusOK, `<a href="intro.md">intro.md</a>`},
+		{"/guide/logo.png", http.StatusOK, "\x89PNG"},
+		{"/broken.md", http.StatusInternalServerError, "undefined footnote"},
+		{"/broken.md", http.StatusInternalServerError, reloadScript},
+		{"/missing.html", http.StatusNotFound, ""},
+		{"/.git/config", http.StatusNotFound, ""},
+		{"/.git/", http.StatusNotFound, ""},
+		{"/guide/.env", http.StatusNotFound, ""},
+		{"/guide/%2e%2e/.git/config", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range testcases {
+		resp, err := http.Get(ts.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: expected status %d, but got %d\n", tt.path, tt.status, resp.StatusCode)
+		}
+		if !strings.Contains(string(b), tt.expected) {
+			t.Errorf("%s: expected %q, but got %q\n", tt.path, tt.expected, b)
+		}
+	}
+
+	// pages are served by the handler with an ETag and a relative redirect
+	resp, err := http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("ETag") == "" || resp.Header.Get("Last-Modified") == "" {
+		t.Errorf("expected an ETag and Last-Modified, but got %v\n", resp.Header)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err = client.Get(ts.URL + "/guide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if loc := resp.Header.Get("Location"); loc != "/guide/" {
+		t.Errorf("expected a redirect to /guide/, but got %q\n", loc)
+	}
+
+	// pages are rendered again when their file changes
+	ioutil.WriteFile(filepath.Join(dir, "index.md"), []byte("# Changed\n"), 0644)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(filepath.Join(dir, "index.md"), later, later)
+	resp, err = http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(b), `<h1 id="changed">Changed</h1>`) {
+		t.Errorf("expected the changed page, but got %q\n", b)
+	}
+
+	// and when their front matter stylesheet changes
+	for _, style := range []string{"h1 { color: red; }", "h1 { color: blue; }"} {
+		ioutil.WriteFile(filepath.Join(dir, "style.css"), []byte(style), 0644)
+		later = later.Add(time.Second)
+		os.Chtimes(filepath.Join(dir, "style.css"), later, later)
+		resp, err := http.Get(ts.URL + "/styled.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(b), style) {
+			t.Errorf("expected %q, but got %q\n", style, b)
+		}
+	}
+
+	// clients are told to reload
+	resp, err = http.Get(ts.URL + eventsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	r := bufio.NewReader(resp.Body)
+	if line, _ := r.ReadString('\n'); line != ": connected\n" {
+		t.Fatalf("expected a comment, but got %q\n", line)
+	}
+	r.ReadString('\n')
+	s.reload()
+	if line, _ := r.ReadString('\n'); line != "data: reload\n" {
+		t.Errorf("expected a reload event, but got %q\n", line)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matsuyoshi30/gom2h"
+	gom2hhttp "github.com/matsuyoshi30/gom2h/http"
+)
+
+// serve
+//
+// gom2h serve [dir] serves the markdown files of dir as html pages on
+// localhost with the http package handler. Pages are rendered on request
+// and cached until the file, -css, -tmpl or the front matter css or
+// template changes. Every page gets a script which reloads it when a file
+// of dir changes, notified with Server-Sent Events.
+
+const eventsPath = "/_gom2h/events"
+
+const reloadScript = `<script>new EventSource("` + eventsPath + `").onmessage = function() { location.reload(); };</script>`
+
+// serveTemplate renders the directory listings and errors of the handler
+var serveTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+</head>
+<body>
+{{ .Content }}
+` + reloadScript + `
+</body>
+</html>
+`))
+
+type cachedPage struct {
+	mod  time.Time
+	html []byte
+	deps []string // the files selected by the front matter
+}
+
+type server struct {
+	cfg     *config
+	dir     string
+	handler http.Handler
+	mu      sync.Mutex
+	cache   map[string]cachedPage
+	clients map[chan struct{}]bool
+}
+
+// runServe serves the directory of args until interrupted
+func runServe(args []string) int {
+	fs := flag.NewFlagSet(name+" serve", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stdout, "usage: %s serve [flags] [dir]\n", name)
+		fs.PrintDefaults()
+	}
+
+	var cfg config
+	var addr string
+	var anchors bool
+	fs.StringVar(&addr, "addr", "localhost:8080", "address to listen on")
+	fs.StringVar(&cfg.cssfile, "css", "", "path to css file")
+	fs.StringVar(&cfg.tmplfile, "tmpl", "", "path to template file")
+	fs.StringVar(&cfg.layouts, "layouts", "", "path to template directory with base, layouts and partials")
+	fs.BoolVar(&anchors, "anchors", false, "add anchor links to headings")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitNG
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exitNG
+	}
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+	if anchors {
+		cfg.opts = append(cfg.opts, gom2h.WithHeadingAnchors())
+	}
+	if cfg.tmplfile != "" && cfg.layouts != "" {
+		fmt.Fprintln(os.Stderr, "cannot use -tmpl with -layouts")
+		return exitNG
+	}
+	cfg.parseLayouts()
+
+	s := newServer(&cfg, dir)
+	paths := []string{dir}
+	for _, f := range []string{cfg.cssfile, cfg.tmplfile, cfg.layouts} {
+		if f != "" {
+			paths = append(paths, f)
+		}
+	}
+	go watchFiles(paths, "", watchInterval, nil, func(changed []string) {
+		fmt.Fprintf(os.Stderr, "changed %s\n", strings.Join(changed, " "))
+		for _, f := range changed {
+			if cfg.isLayout(f) {
+				s.parseLayouts()
+				break
+			}
+		}
+		s.reload()
+	})
+
+	fmt.Fprintf(os.Stderr, "serving %s on http://%s/\n", dir, addr)
+	if err := http.ListenAndServe(addr, s); err != nil {
+		fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
+		return exitNG
+	}
+	return exitOK
+}
+
+func newServer(cfg *config, dir string) *server {
+	s := &server{
+		cfg:     cfg,
+		dir:     dir,
+		cache:   make(map[string]cachedPage),
+		clients: make(map[chan struct{}]bool),
+	}
+	s.handler = gom2hhttp.NewHandler(os.DirFS(dir), gom2hhttp.WithTemplate(serveTemplate), gom2hhttp.WithRender(s.render))
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == eventsPath {
+		s.events(w, r)
+		return
+	}
+	s.handler.ServeHTTP(w, r)
+}
+
+// render returns the page of the markdown file name of dir, rendered again
+// when the file or its stylesheet or template has changed
+func (s *server) render(name string, b []byte) ([]byte, error) {
+	file := filepath.Join(s.dir, filepath.FromSlash(name))
+	s.mu.Lock()
+	c, ok := s.cache[file]
+	cfg := *s.cfg
+	s.mu.Unlock()
+
+	modTime := func(files []string) time.Time {
+		var mod time.Time
+		for _, f := range files {
+			if info, err := os.Stat(f); err == nil && info.ModTime().After(mod) {
+				mod = info.ModTime()
+			}
+		}
+		return mod
+	}
+	mod := modTime(append([]string{file, cfg.cssfile, cfg.tmplfile}, c.deps...))
+
+	if !ok || !c.mod.Equal(mod) {
+		c.deps = metaFiles(file)
+		mod = modTime(append([]string{file, cfg.cssfile, cfg.tmplfile}, c.deps...))
+		page, err := htmlPage(&cfg, file, b, cfg.opts)
+		if err != nil {
+			return nil, err
+		}
+		c.mod, c.html = mod, withReload(page)
+		s.mu.Lock()
+		s.cache[file] = c
+		s.mu.Unlock()
+	}
+	return c.html, nil
+}
+
+// events notifies the client of changes until it disconnects
+func (s *server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	ch := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.clients[ch] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, ch)
+		s.mu.Unlock()
+	}()
+
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// parseLayouts parses the changed -layouts for the following pages
+func (s *server) parseLayouts() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg.parseLayouts()
+}
+
+// reload drops the cached pages and tells the clients to reload
+func (s *server) reload() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// front matter may select another stylesheet or template
+	s.cache = make(map[string]cachedPage)
+	for ch := range s.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// withReload adds the reload script to the end of the body of page
+func withReload(page []byte) []byte {
+	i := bytes.LastIndex(page, []byte("</body>"))
+	if i < 0 {
+		return append(append([]byte(nil), page...), reloadScript...)
+	}
+	ret := make([]byte, 0, len(page)+len(reloadScript)+1)
+	ret = append(ret, page[:i]...)
+	ret = append(ret, reloadScript+"\n"...)
+	return append(ret, page[i:]...)
+}
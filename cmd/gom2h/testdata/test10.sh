@@ -0,0 +1,2 @@
+#!/bin/bash
+../gom2h -format term -no-color -width 30 test10.md > test10.txt
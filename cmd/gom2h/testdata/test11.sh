@@ -0,0 +1,2 @@
+#!/bin/bash
+../gom2h -format man test11.1.md
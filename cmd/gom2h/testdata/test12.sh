@@ -0,0 +1,2 @@
+#!/bin/bash
+../gom2h -format latex test12.md
@@ -0,0 +1,2 @@
+#!/bin/bash
+../gom2h -format text - < test13.md > test13.txt
@@ -0,0 +1,2 @@
+#!/bin/bash
+../gom2h -o test14.html test14.text
@@ -0,0 +1,2 @@
+#!/bin/bash
+../gom2h -css test.css test15.md
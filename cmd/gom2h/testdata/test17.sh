@@ -0,0 +1,2 @@
+#!/bin/bash
+../gom2h -tmpl test17.tmpl - < test17.md > test17.html
@@ -0,0 +1,2 @@
+#!/bin/bash
+../gom2h -format epub - < test17.md > test18.epub && test ! -e stdin.epub
@@ -0,0 +1,2 @@
+#!/bin/bash
+../gom2h -css test.css -anchors test4.md
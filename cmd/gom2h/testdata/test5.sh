@@ -0,0 +1,2 @@
+#!/bin/bash
+../gom2h -tmpl test5.tmpl test5.md
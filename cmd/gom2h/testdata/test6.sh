@@ -0,0 +1,2 @@
+#!/bin/bash
+../gom2h test6.md
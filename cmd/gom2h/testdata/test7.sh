@@ -0,0 +1,2 @@
+#!/bin/bash
+../gom2h fmt -soft-breaks -wrap 40 test7.md > test7.html
@@ -0,0 +1,2 @@
+#!/bin/bash
+../gom2h -format text -no-code test8.md
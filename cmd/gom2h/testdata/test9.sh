@@ -0,0 +1,2 @@
+#!/bin/bash
+../gom2h -format json test9.md
@@ -5,6 +5,7 @@ const index = `<!DOCTYPE html>
   <head>
     <meta charset="utf-8">
     <meta name="viewport" content="width=device-width, initial-scale=1, minimal-ui">
+    <title>{{ .Title }}</title>
     <link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/highlight.js/9.18.1/styles/default.min.css">
     <script src="https://cdnjs.cloudflare.com/ajax/libs/highlight.js/9.18.1/highlight.min.js"></script>
     <script>hljs.initHighlightingOnLoad();</script>
@@ -33,3 +34,147 @@ const index = `<!DOCTYPE html>
   </body>
 </html>
 `
+
+const latexIndex = `\documentclass{article}
+\usepackage[utf8]{inputenc}
+\usepackage[T1]{fontenc}
+\usepackage{graphicx}
+\usepackage{listings}
+\usepackage[normalem]{ulem}
+\usepackage{hyperref}
+
+\lstset{basicstyle=\ttfamily\small,breaklines=true}
+
+\title{ {{- .Title -}} }
+\date{}
+
+\begin{document}
+
+\maketitle
+
+{{ .Content }}
+\end{document}
+`
+
+const epubContainer = `<?xml version="1.0" encoding="utf-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+const epubPackage = `<?xml version="1.0" encoding="utf-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="{{ xml .Lang }}">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{ .ID }}</dc:identifier>
+    <dc:title>{{ xml .Title }}</dc:title>
+    <dc:language>{{ xml .Lang }}</dc:language>
+    {{- if .Author }}
+    <dc:creator>{{ xml .Author }}</dc:creator>
+    {{- end }}
+    <meta property="dcterms:modified">{{ .Modified }}</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+    {{- range .Chapters }}
+    <item id="{{ .ID }}" href="{{ xml .Href }}" media-type="application/xhtml+xml"{{ if .Remote }} properties="remote-resources"{{ end }}/>
+    {{- end }}
+    {{- range .Images }}
+    <item id="{{ .ID }}" href="{{ xml .Href }}" media-type="{{ .MediaType }}"/>
+    {{- end }}
+  </manifest>
+  <spine>
+    {{- range .Chapters }}
+    <itemref idref="{{ .ID }}"/>
+    {{- end }}
+  </spine>
+</package>
+`
+
+const epubNav = `<?xml version="1.0" encoding="utf-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="{{ xml .Lang }}" xml:lang="{{ xml .Lang }}">
+  <head>
+    <meta charset="utf-8"/>
+    <title>{{ xml .Title }}</title>
+  </head>
+  <body>
+    <nav epub:type="toc" id="toc">
+      <h1>{{ xml .Title }}</h1>
+{{ .Nav }}
+    </nav>
+  </body>
+</html>
+`
+
+const epubContent = `<?xml version="1.0" encoding="utf-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="{{ xml .Lang }}" xml:lang="{{ xml .Lang }}">
+  <head>
+    <meta charset="utf-8"/>
+    <title>{{ xml .Chapter.Title }}</title>
+    <link rel="stylesheet" type="text/css" href="style.css"/>
+  </head>
+  <body>
+    <article class="markdown-body">
+{{ .Chapter.Content }}
+    </article>
+  </body>
+</html>
+`
+
+const siteLayout = `<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1, minimal-ui">
+    <title>{{ .Title }}</title>
+    <style>
+      {{ .Stylesheet }}
+    </style>
+    <style>
+      body {
+        box-sizing: border-box;
+        display: flex;
+        margin: 0 auto;
+        max-width: 1280px;
+      }
+      .sidebar {
+        flex: 0 0 240px;
+        padding: 45px 20px;
+      }
+      .sidebar ul {
+        list-style: none;
+        padding-left: 1em;
+      }
+      .sidebar .active > a {
+        font-weight: bold;
+      }
+      .markdown-body {
+        flex: 1;
+        min-width: 200px;
+        padding: 45px;
+      }
+      @media (max-width: 767px) {
+        body {
+          display: block;
+        }
+        .markdown-body {
+          padding: 15px;
+        }
+      }
+    </style>
+  </head>
+  <body>
+    <nav class="sidebar">
+      <a href="{{ .Root }}">{{ .Site }}</a>
+      {{ .Nav }}
+    </nav>
+    <article class="markdown-body">
+      {{ .Content }}
+    </article>
+  </body>
+</html>
+`
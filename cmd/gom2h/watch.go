@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matsuyoshi30/gom2h"
+)
+
+// watch
+//
+// With -watch the files are converted again when they change. The files,
+// directory trees, -css, -tmpl and -layouts are polled, and the stylesheets
+// and templates selected by front matter; a burst of writes is converted
+// once after the files have been quiet for a poll interval. The output
+// files of removed files are removed.
+
+const watchInterval = 500 * time.Millisecond
+
+// runWatch converts the files of args and converts them again on changes
+// until interrupted
+func runWatch(cfg *config, args []string, output, outdir string, jobs int) int {
+	for _, arg := range args {
+		if arg == "-" {
+			fmt.Fprintln(os.Stderr, "cannot use -watch with standard input")
+			return exitNG
+		}
+	}
+
+	var mu sync.Mutex
+	outputs := make(map[string]string)
+	cfg.written = func(src, output string) {
+		mu.Lock()
+		outputs[src] = output
+		mu.Unlock()
+	}
+
+	convert := func(changed []string) {
+		switch {
+		case cfg.format == "epub":
+			runEPUB(append([]string(nil), args...), output, cfg.cssfile, cfg.opts)
+		case outdir != "":
+			var only map[string]bool
+			if changed != nil {
+				only = make(map[string]bool)
+				for _, f := range changed {
+					only[f] = true
+				}
+			}
+			runBatch(cfg, args, outdir, jobs, only)
+		default:
+			convertFile(cfg, args[0], output, "")
+		}
+	}
+	convert(nil)
+
+	paths := append([]string(nil), args...)
+	for _, f := range []string{cfg.cssfile, cfg.tmplfile, cfg.layouts} {
+		if f != "" {
+			paths = append(paths, f)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "watching %s\n", strings.Join(paths, " "))
+	watchFiles(paths, outdir, watchInterval, nil, func(changed []string) {
+		fmt.Fprintf(os.Stderr, "changed %s\n", strings.Join(changed, " "))
+		all, reparse := false, false
+		for _, f := range changed {
+			// the stylesheet and templates are used by all files
+			switch {
+			case cfg.isLayout(f):
+				all, reparse = true, true
+			case f == cfg.cssfile || f == cfg.tmplfile:
+				all = true
+			}
+		}
+		if reparse {
+			cfg.parseLayouts()
+		}
+		removeOutputs(outputs, changed)
+		if all {
+			changed = nil
+		} else {
+			changed = append(changed, dependents(args, outdir, changed)...)
+		}
+		convert(changed)
+	})
+	return exitOK
+}
+
+// watchFiles polls the files of paths every interval and calls fn with
+// the changed, added and removed files once they are quiet, until stop is
+// closed
+func watchFiles(paths []string, outdir string, interval time.Duration, stop <-chan struct{}, fn func(changed []string)) {
+	wait := func() bool {
+		select {
+		case <-stop:
+			return false
+		case <-time.After(interval):
+			return true
+		}
+	}
+
+	deps := make(map[string]metaDeps)
+	prev := modTimes(paths, outdir, deps)
+	for wait() {
+		cur := modTimes(paths, outdir, deps)
+		changed := changedFiles(prev, cur)
+		if len(changed) == 0 {
+			continue
+		}
+
+		// wait for the end of a burst of writes
+		for wait() {
+			next := modTimes(paths, outdir, deps)
+			more := changedFiles(cur, next)
+			cur = next
+			if len(more) == 0 {
+				break
+			}
+			changed = append(changed, more...)
+		}
+		prev = cur
+
+		sort.Strings(changed)
+		uniq := changed[:0]
+		for i, f := range changed {
+			if i == 0 || f != changed[i-1] {
+				uniq = append(uniq, f)
+			}
+		}
+		fn(uniq)
+	}
+}
+
+// metaDeps are the files selected by the front matter of a markdown file,
+// read again when the file changes
+type metaDeps struct {
+	mod   time.Time
+	files []string
+}
+
+// modTimes returns the files of paths and the files their front matter
+// selects with their modification times
+func modTimes(paths []string, outdir string, deps map[string]metaDeps) map[string]time.Time {
+	times := make(map[string]time.Time)
+	for _, p := range paths {
+		// missing files are reported when converting
+		list, _ := batchJobs([]string{p}, outdir)
+		for _, job := range list {
+			info, err := os.Stat(job.src)
+			if err != nil {
+				continue
+			}
+			times[job.src] = info.ModTime()
+			if job.copy {
+				continue
+			}
+			d, ok := deps[job.src]
+			if !ok || !d.mod.Equal(info.ModTime()) {
+				d = metaDeps{mod: info.ModTime(), files: metaFiles(job.src)}
+				deps[job.src] = d
+			}
+			for _, f := range d.files {
+				if info, err := os.Stat(f); err == nil {
+					times[f] = info.ModTime()
+				}
+			}
+		}
+	}
+	return times
+}
+
+// metaFiles returns the stylesheet and template selected by the front
+// matter of the markdown file filename
+func metaFiles(filename string) []string {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	res, err := gom2h.Convert(b)
+	if err != nil {
+		return nil
+	}
+	files := make([]string, 0)
+	cssfile, tmplfile := pageFiles(&config{}, filename, res.Meta)
+	for _, f := range []string{cssfile, tmplfile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// dependents returns the markdown files of args whose front matter
+// selects one of files
+func dependents(args []string, outdir string, files []string) []string {
+	used := make(map[string]bool)
+	for _, f := range files {
+		used[f] = true
+	}
+	ret := make([]string, 0)
+	list, _ := batchJobs(args, outdir)
+	for _, job := range list {
+		if job.copy {
+			continue
+		}
+		for _, f := range metaFiles(job.src) {
+			if used[f] {
+				ret = append(ret, job.src)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// removeOutputs removes the output files of the removed files of changed
+func removeOutputs(outputs map[string]string, changed []string) {
+	for _, f := range changed {
+		out, ok := outputs[f]
+		if _, err := os.Stat(f); !ok || !os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Remove(out); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "unexpected error: %v\n", err)
+			continue
+		}
+		delete(outputs, f)
+		fmt.Fprintf(os.Stderr, "removed %s\n", out)
+	}
+}
+
+func changedFiles(prev, cur map[string]time.Time) []string {
+	changed := make([]string, 0)
+	for f, t := range cur {
+		if p, ok := prev[f]; !ok || !p.Equal(t) {
+			changed = append(changed, f)
+		}
+	}
+	for f := range prev {
+		if _, ok := cur[f]; !ok {
+			changed = append(changed, f)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,147 @@
+package gom2h
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// emphasis, strong and strikethrough
+//
+// Delimiter runs (`*`, `_`, `~`) are matched with the algorithm described in
+// https://spec.commonmark.org/0.30/#emphasis-and-strong-emphasis and
+// https://github.github.com/gfm/#strikethrough-extension-
+
+type delim struct {
+	c        byte
+	n        int // remaining delimiter count
+	orig     int // original delimiter count
+	canOpen  bool
+	canClose bool
+	active   bool
+	open     []match // nodes opened after the remaining delimiters, outermost first
+	close    []match // nodes closed before the remaining delimiters, innermost first
+	src      []byte
+}
+
+// match is a node opened or closed by n delimiters
+type match struct {
+	kind ast.Kind
+	n    int
+}
+
+// segment is either plain text, a delimiter run or an inline node
+type segment struct {
+	text []byte
+	d    *delim
+	node ast.Node
+}
+
+func isDelim(c byte) bool {
+	return c == '*' || c == '_' || c == '~'
+}
+
+// emphasis matches the delimiter runs of segs
+func emphasis(segs []segment) {
+	for ci := range segs {
+		closer := segs[ci].d
+		if closer == nil || !closer.canClose {
+			continue
+		}
+		for closer.n > 0 {
+			oi := opener(segs, ci)
+			if oi < 0 {
+				break
+			}
+			op := segs[oi].d
+
+			n := 1
+			if op.n >= 2 && closer.n >= 2 {
+				n = 2
+			}
+			if closer.c == '~' {
+				n = closer.n
+			}
+
+			var kind ast.Kind
+			switch {
+			case closer.c == '~':
+				kind = ast.KindStrikethrough
+			case n == 2:
+				kind = ast.KindStrong
+			default:
+				kind = ast.KindEmphasis
+			}
+			op.open = append([]match{{kind, n}}, op.open...)
+			closer.close = append(closer.close, match{kind, n})
+			op.n -= n
+			closer.n -= n
+
+			// delimiters between opener and closer can no longer match
+			for k := oi + 1; k < ci; k++ {
+				if segs[k].d != nil {
+					segs[k].d.active = false
+				}
+			}
+			if op.n == 0 {
+				op.active = false
+			}
+		}
+	}
+}
+
+// opener returns the index of the nearest segment before ci which can be
+// matched with the closer at ci, or -1
+func opener(segs []segment, ci int) int {
+	closer := segs[ci].d
+	for oi := ci - 1; oi >= 0; oi-- {
+		op := segs[oi].d
+		if op == nil || !op.active || !op.canOpen || op.c != closer.c || op.n == 0 {
+			continue
+		}
+		if closer.c == '~' {
+			// strikethrough needs runs of the same length, one or two tildes
+			if op.n != closer.n || op.n > 2 {
+				continue
+			}
+			return oi
+		}
+		// rule of 3
+		if (op.canClose || closer.canOpen) &&
+			(op.orig+closer.orig)%3 == 0 && !(op.orig%3 == 0 && closer.orig%3 == 0) {
+			continue
+		}
+		return oi
+	}
+	return -1
+}
+
+func newDelim(line []byte, s, e int) *delim {
+	before, after := ' ', ' '
+	if s > 0 {
+		before, _ = utf8.DecodeLastRune(line[:s])
+	}
+	if e < len(line) {
+		after, _ = utf8.DecodeRune(line[e:])
+	}
+
+	left := !unicode.IsSpace(after) &&
+		(!isPunct(after) || unicode.IsSpace(before) || isPunct(before))
+	right := !unicode.IsSpace(before) &&
+		(!isPunct(before) || unicode.IsSpace(after) || isPunct(after))
+
+	d := &delim{c: line[s], n: e - s, orig: e - s, active: true, src: line[s:e]}
+	if d.c == '_' {
+		d.canOpen = left && (!right || isPunct(before))
+		d.canClose = right && (!left || isPunct(after))
+	} else {
+		d.canOpen = left
+		d.canClose = right
+	}
+	return d
+}
+
+func isPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
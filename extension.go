@@ -0,0 +1,127 @@
+package gom2h
+
+import (
+	"bytes"
+
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// extensions
+//
+// Custom syntax is added with block and inline parsers. They are tried in
+// the order they were registered and before the built-in syntax.
+// Parsers may return custom nodes, whose kinds are registered with
+// ast.NewBlockKind or ast.NewInlineKind and rendered by a NodeRenderFunc.
+
+// BlockParser parses a custom block
+type BlockParser interface {
+	// Trigger returns the bytes the first line of the block may start with.
+	// An empty trigger tries the parser on every line.
+	Trigger() []byte
+	// Open reports whether line opens a block and returns the block
+	Open(line []byte) (Block, bool)
+}
+
+// BlockState tells the parser what to do after Block.Continue
+type BlockState int
+
+const (
+	// BlockContinue consumes the line and keeps the block open
+	BlockContinue BlockState = iota
+	// BlockClose consumes the line and closes the block
+	BlockClose
+	// BlockStop closes the block and parses the line as usual
+	BlockStop
+)
+
+// Block is a custom block opened by a BlockParser
+type Block interface {
+	// Continue is called with each line following the opening line,
+	// including empty lines, until the block is closed
+	Continue(line []byte) BlockState
+	// Close returns the node of the closed block. Nested markdown lines may
+	// be parsed into blocks appended to a node with parse.
+	Close(parse func(parent ast.Node, lines [][]byte) error) (ast.Node, error)
+}
+
+// InlineParser parses a custom inline span
+type InlineParser interface {
+	// Trigger returns the bytes the span may start with.
+	// An empty trigger tries the parser at every byte.
+	Trigger() []byte
+	// Parse is called with the rest of the line starting at a trigger byte.
+	// It returns the node of the span and the number of bytes it consumed,
+	// or 0 if the line does not start with the span.
+	Parse(line []byte) (ast.Node, int)
+}
+
+// NodeRenderFunc renders a node whose children are already rendered
+type NodeRenderFunc func(r Renderer, n ast.Node, children []byte) []byte
+
+// WithBlockParser adds custom block syntax
+func WithBlockParser(p BlockParser) Option {
+	return func(c *converter) {
+		c.blocks = append(c.blocks, p)
+	}
+}
+
+// WithInlineParser adds custom inline syntax
+func WithInlineParser(p InlineParser) Option {
+	return func(c *converter) {
+		c.inlines = append(c.inlines, p)
+	}
+}
+
+// WithNodeRenderer renders nodes of kind with fn instead of the Renderer.
+// The last registered function for a kind is used.
+func WithNodeRenderer(kind ast.Kind, fn NodeRenderFunc) Option {
+	return func(c *converter) {
+		c.hooks[kind] = fn
+	}
+}
+
+func triggered(trigger []byte, line []byte) bool {
+	return len(trigger) == 0 || bytes.IndexByte(trigger, line[0]) >= 0
+}
+
+// openBlock returns the block opened by line, if any
+func (c *converter) openBlock(line []byte) (Block, bool) {
+	for _, p := range c.blocks {
+		if !triggered(p.Trigger(), line) {
+			continue
+		}
+		if b, ok := p.Open(line); ok {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// closeBlock appends the node of b, whose source are lines from the first
+// to the last of lines, to parent
+func (c *converter) closeBlock(parent ast.Node, b Block, lines [2][]byte) error {
+	n, err := b.Close(c.parse)
+	if err != nil {
+		return err
+	}
+	if n.Span().IsZero() {
+		n.SetSpan(c.src.spanOf(lines[0], lines[1]))
+	}
+	ast.AppendChild(parent, n)
+	return nil
+}
+
+func (c *converter) parseCustomInline(line []byte) (ast.Node, int) {
+	for _, p := range c.inlines {
+		if !triggered(p.Trigger(), line) {
+			continue
+		}
+		if n, size := p.Parse(line); size > 0 {
+			if n.Span().IsZero() {
+				n.SetSpan(c.src.span(line[:size]))
+			}
+			return n, size
+		}
+	}
+	return nil, 0
+}
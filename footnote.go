@@ -0,0 +1,137 @@
+package gom2h
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// footnotes
+//
+// Definitions are collected from the whole document before parsing, and
+// references are numbered in the order they first appear.
+
+var (
+	footnoteDefExp = regexp.MustCompile(`^\[\^([^\]\s]+)\]:[ \t]*(.*)`)
+	footnoteRefExp = regexp.MustCompile(`^\[\^([^\]\s]+)\]`)
+)
+
+type footnote struct {
+	label string // as written in the definition
+	num   int
+	lines [][]byte
+	refs  int
+}
+
+type footnotes struct {
+	defs    map[string]*footnote
+	defined []*footnote // in source order
+	order   []*footnote // in order of the first reference
+}
+
+// collectFootnotes removes footnote definitions from lines.
+// Lines indented by four spaces or a tab following a definition belong to it.
+func collectFootnotes(lines [][]byte) ([][]byte, *footnotes) {
+	fns := &footnotes{defs: make(map[string]*footnote)}
+
+	ret := make([][]byte, 0, len(lines))
+	var cur *footnote
+	var blank [][]byte // blank lines following cur
+	inFence := false
+	for _, line := range lines {
+		if cur != nil {
+			if len(bytes.TrimSpace(line)) == 0 {
+				blank = append(blank, line)
+				continue
+			}
+			if body, ok := dedent(line); ok {
+				// blank lines separate the paragraphs of a definition
+				for range blank {
+					cur.lines = append(cur.lines, []byte{})
+				}
+				cur.lines = append(cur.lines, body)
+				blank = nil
+				continue
+			}
+			ret = append(ret, blank...)
+			cur, blank = nil, nil
+		}
+
+		if codefenceExp.Match(line) {
+			inFence = !inFence
+		}
+		if !inFence && footnoteDefExp.Match(line) {
+			m := footnoteDefExp.FindSubmatch(line)
+			cur = &footnote{label: string(m[1]), lines: [][]byte{m[2]}}
+			label := strings.ToLower(string(m[1]))
+			if _, ok := fns.defs[label]; !ok {
+				fns.defs[label] = cur
+				fns.defined = append(fns.defined, cur)
+			}
+			continue
+		}
+
+		ret = append(ret, line)
+	}
+
+	return ret, fns
+}
+
+func dedent(line []byte) ([]byte, bool) {
+	if bytes.HasPrefix(line, []byte("\t")) {
+		return line[1:], true
+	}
+	if bytes.HasPrefix(line, []byte("    ")) {
+		return line[4:], true
+	}
+	return nil, false
+}
+
+// ref returns the next reference to the footnote with label, or nil if
+// there is no such footnote
+func (fns *footnotes) ref(label []byte) *ast.FootnoteRef {
+	fn, ok := fns.defs[strings.ToLower(string(label))]
+	if !ok {
+		return nil
+	}
+	if fn.num == 0 {
+		fns.order = append(fns.order, fn)
+		fn.num = len(fns.order)
+	}
+	fn.refs++
+
+	return &ast.FootnoteRef{Num: fn.num, Ref: fn.refs}
+}
+
+func fnrefID(num, ref int) string {
+	if ref == 1 {
+		return fmt.Sprintf("fnref-%d", num)
+	}
+	return fmt.Sprintf("fnref-%d-%d", num, ref)
+}
+
+// parse appends the referenced footnotes to doc
+func (fns *footnotes) parse(c *converter, doc ast.Node) error {
+	if len(fns.order) == 0 {
+		return nil
+	}
+
+	section := &ast.Footnotes{}
+	// parsing a definition may reference further footnotes
+	for i := 0; i < len(fns.order); i++ {
+		fn := &ast.Footnote{Num: fns.order[i].num}
+		if err := c.parse(fn, fns.order[i].lines); err != nil {
+			return err
+		}
+		ast.AppendChild(section, fn)
+	}
+	for i, n := range ast.Children(section) {
+		n.(*ast.Footnote).Refs = fns.order[i].refs
+	}
+	ast.AppendChild(doc, section)
+
+	return nil
+}
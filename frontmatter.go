@@ -0,0 +1,389 @@
+package gom2h
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// front matter
+//
+// A document may start with YAML front matter between --- lines or TOML
+// front matter between +++ lines. A common subset of both is supported:
+// strings, numbers, booleans, lists and nested tables.
+
+var (
+	yamlKeyExp   = regexp.MustCompile(`^([^\s:#"'\-][^:]*?|-[^\s:][^:]*?):(?:[ \t]+(.*))?$`)
+	tomlKeyExp   = regexp.MustCompile(`^([A-Za-z0-9_\-."' ]+?)[ \t]*=[ \t]*(.*)$`)
+	tomlTableExp = regexp.MustCompile(`^\[([^\[\]]+)\]$`)
+	dateExp      = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}([T ][0-9:.]+(Z|[+\-]\d{2}:\d{2})?)?$`)
+)
+
+// frontMatter removes leading front matter from lines and parses it
+func frontMatter(src *source, lines [][]byte) (map[string]interface{}, [][]byte, error) {
+	if len(lines) == 0 {
+		return nil, lines, nil
+	}
+
+	delim := string(bytes.TrimRight(lines[0], " \t\r"))
+	if delim != "---" && delim != "+++" {
+		return nil, lines, nil
+	}
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		l := string(bytes.TrimRight(lines[i], " \t\r"))
+		if l == delim || (delim == "---" && l == "...") {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil, lines, nil
+	}
+
+	var meta map[string]interface{}
+	var err error
+	if delim == "---" {
+		meta, err = parseYAML(src, lines[1:end])
+	} else {
+		meta, err = parseTOML(src, lines[1:end])
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return meta, lines[end+1:], nil
+}
+
+func frontMatterError(src *source, line []byte, format string, a ...interface{}) error {
+	return src.errorAt(line, "front matter: %s", fmt.Sprintf(format, a...))
+}
+
+// yaml
+
+type yamlLine struct {
+	src    []byte // the line from text on
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	src   *source
+	lines []yamlLine
+	pos   int
+}
+
+func parseYAML(src *source, lines [][]byte) (map[string]interface{}, error) {
+	p := &yamlParser{src: src}
+	for _, line := range lines {
+		s := strings.TrimRight(string(line), " \t\r")
+		t := strings.TrimLeft(s, " ")
+		if t == "" || strings.HasPrefix(t, "#") {
+			continue
+		}
+		p.lines = append(p.lines, yamlLine{src: line[len(s)-len(t):], indent: len(s) - len(t), text: t})
+	}
+	if len(p.lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	m, err := p.mapping(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, frontMatterError(p.src, p.lines[p.pos].src, "unexpected indentation")
+	}
+	return m, nil
+}
+
+func (p *yamlParser) mapping(indent int) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, frontMatterError(p.src, l.src, "unexpected indentation")
+		}
+		kv := yamlKeyExp.FindStringSubmatch(l.text)
+		if kv == nil {
+			return nil, frontMatterError(p.src, l.src, "expected key: value")
+		}
+		p.pos++
+
+		key := strings.TrimSpace(kv[1])
+		if val := stripComment(kv[2]); val != "" {
+			v, err := yamlValue(val)
+			if err != nil {
+				return nil, frontMatterError(p.src, l.src, "%v", err)
+			}
+			m[key] = v
+			continue
+		}
+
+		// block value on the following lines
+		m[key] = nil
+		if p.pos == len(p.lines) {
+			continue
+		}
+		next := p.lines[p.pos]
+		var err error
+		switch {
+		case isYAMLItem(next.text) && next.indent >= indent:
+			m[key], err = p.sequence(next.indent)
+		case next.indent > indent:
+			m[key], err = p.mapping(next.indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (p *yamlParser) sequence(indent int) ([]interface{}, error) {
+	items := make([]interface{}, 0)
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent != indent || !isYAMLItem(l.text) {
+			break
+		}
+
+		item := strings.TrimLeft(l.text[1:], " ")
+		if yamlKeyExp.MatchString(item) {
+			// - key: value
+			// the item is a mapping starting at the column of key
+			p.lines[p.pos] = yamlLine{src: l.src[len(l.text)-len(item):], indent: l.indent + len(l.text) - len(item), text: item}
+			m, err := p.mapping(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, m)
+			continue
+		}
+		p.pos++
+
+		v, err := yamlValue(stripComment(item))
+		if err != nil {
+			return nil, frontMatterError(p.src, l.src, "%v", err)
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+func isYAMLItem(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "- ")
+}
+
+func yamlValue(s string) (interface{}, error) {
+	switch {
+	case s == "":
+		return nil, nil
+	case strings.HasPrefix(s, `"`), strings.HasPrefix(s, `'`):
+		return unquote(s)
+	case strings.HasPrefix(s, "["):
+		return array(s, yamlValue)
+	}
+
+	switch strings.ToLower(s) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null", "~":
+		return nil, nil
+	}
+	if v, ok := number(s); ok {
+		return v, nil
+	}
+	return s, nil
+}
+
+// toml
+
+func parseTOML(src *source, lines [][]byte) (map[string]interface{}, error) {
+	root := make(map[string]interface{})
+	cur := root
+	for _, line := range lines {
+		s := strings.TrimSpace(stripComment(string(line)))
+		if s == "" {
+			continue
+		}
+
+		if m := tomlTableExp.FindStringSubmatch(s); m != nil {
+			// [table.sub]
+			t, err := table(root, splitKey(m[1]))
+			if err != nil {
+				return nil, frontMatterError(src, bytes.TrimLeft(line, " \t"), "%v", err)
+			}
+			cur = t
+			continue
+		}
+
+		kv := tomlKeyExp.FindStringSubmatch(s)
+		if kv == nil {
+			return nil, frontMatterError(src, bytes.TrimLeft(line, " \t"), "expected key = value")
+		}
+		keys := splitKey(kv[1])
+		t, err := table(cur, keys[:len(keys)-1])
+		if err != nil {
+			return nil, frontMatterError(src, bytes.TrimLeft(line, " \t"), "%v", err)
+		}
+		v, err := tomlValue(kv[2])
+		if err != nil {
+			return nil, frontMatterError(src, bytes.TrimLeft(line, " \t"), "%v", err)
+		}
+		t[keys[len(keys)-1]] = v
+	}
+	return root, nil
+}
+
+// table returns the nested table of m at keys, creating it if needed
+func table(m map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for _, key := range keys {
+		v, ok := m[key]
+		if !ok {
+			t := make(map[string]interface{})
+			m[key] = t
+			m = t
+			continue
+		}
+		t, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%q is not a table", key)
+		}
+		m = t
+	}
+	return m, nil
+}
+
+func splitKey(s string) []string {
+	keys := make([]string, 0)
+	for _, k := range split(s, '.') {
+		if u, err := unquote(k); err == nil {
+			k = u.(string)
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func tomlValue(s string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(s, `"`), strings.HasPrefix(s, `'`):
+		return unquote(s)
+	case strings.HasPrefix(s, "["):
+		return array(s, tomlValue)
+	case s == "true":
+		return true, nil
+	case s == "false":
+		return false, nil
+	case dateExp.MatchString(s):
+		return s, nil
+	}
+	if v, ok := number(strings.Replace(s, "_", "", -1)); ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("invalid value %s", s)
+}
+
+// shared
+
+// stripComment removes a # comment outside of quotes
+func stripComment(s string) string {
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return strings.TrimSpace(s[:i])
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+func unquote(s string) (interface{}, error) {
+	if len(s) < 2 || s[len(s)-1] != s[0] {
+		return nil, fmt.Errorf("unterminated string %s", s)
+	}
+	if s[0] == '\'' {
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	u, err := strconv.Unquote(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid string %s", s)
+	}
+	return u, nil
+}
+
+func number(s string) (interface{}, bool) {
+	if s == "" || !strings.ContainsRune("0123456789+-.", rune(s[0])) {
+		return nil, false
+	}
+	if i, err := strconv.Atoi(s); err == nil {
+		return i, true
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, true
+	}
+	return nil, false
+}
+
+// array parses a flow list like [a, "b", [c]]
+func array(s string, value func(string) (interface{}, error)) (interface{}, error) {
+	if !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("unterminated list %s", s)
+	}
+	items := make([]interface{}, 0)
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	if inner == "" {
+		return items, nil
+	}
+	for _, item := range split(inner, ',') {
+		if item == "" {
+			// trailing comma
+			continue
+		}
+		v, err := value(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+// split splits s at sep outside of quotes and brackets
+func split(s string, sep rune) []string {
+	ret := make([]string, 0)
+	var quote rune
+	depth, start := 0, 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		case r == sep && depth == 0:
+			ret = append(ret, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(ret, strings.TrimSpace(s[start:]))
+}
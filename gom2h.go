@@ -2,164 +2,355 @@ package gom2h
 
 import (
 	"bytes"
-	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+
+	"github.com/matsuyoshi30/gom2h/ast"
 )
 
 var nl = []byte("\n")
 
 // main entry point
-func Run(input []byte) ([]byte, error) {
-	input = bytes.TrimSpace(input)
+func Run(input []byte, opts ...Option) ([]byte, error) {
+	res, err := Convert(input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return res.HTML, nil
+}
 
-	conved := make([]Line, 0)
-	for _, line := range bytes.Split(input, nl) {
-		if len(line) == 0 {
-			continue
-		}
-		l, err := conv(line)
-		if err != nil {
+// Result is the output of a conversion
+type Result struct {
+	HTML     []byte
+	Headings []Heading
+	Meta     map[string]interface{}
+	// WordCount is the number of words of the text, code included
+	WordCount int
+}
+
+// Convert converts markdown to html and reports the document headings,
+// front matter and word count
+func Convert(input []byte, opts ...Option) (*Result, error) {
+	c := newConverter(opts)
+	doc, err := c.convert(input)
+	if err != nil {
+		return nil, err
+	}
+
+	words := len(strings.Fields(strings.Join(c.textBlocks(doc, 0), " ")))
+	return &Result{HTML: c.render(doc), Headings: c.headings, Meta: doc.Meta, WordCount: words}, nil
+}
+
+// convert parses input and transforms the document for rendering
+func (c *converter) convert(input []byte) (*ast.Document, error) {
+	doc, _, err := c.parseInput(input)
+	if err != nil {
+		return nil, err
+	}
+	c.headingIDs(doc)
+
+	for _, t := range c.transformers {
+		if err := t.Transform(doc); err != nil {
 			return nil, err
 		}
-		conved = append(conved, l)
 	}
+	// transformers may add headings
+	c.headingIDs(doc)
+	c.headings = headings(doc)
 
-	return render(conved), nil
+	return doc, nil
 }
 
-// convert markdown line to Line
+// parseInput parses input and returns the document with the lines of its
+// front matter
+func (c *converter) parseInput(input []byte) (*ast.Document, [][]byte, error) {
+	c.src = newSource(input)
+	input = bytes.TrimSpace(input)
 
-type LineType int
+	all := bytes.Split(input, nl)
+	meta, lines, err := frontMatter(c.src, all)
+	if err != nil {
+		if c.strict {
+			return nil, nil, err
+		}
+		// not front matter but markdown, e.g. a paragraph between
+		// thematic breaks
+		meta, lines = nil, all
+	}
 
-const (
-	Header LineType = iota
-	Blockquote
-	List
-	CodeFence
-	Paragraph
-	HTMLBlock
-	NewLine
-)
+	// the document decides its paragraphs, so that every output and
+	// Format agree on them
+	if on, ok := meta["softbreaks"].(bool); ok {
+		c.softBreaks = on
+	}
 
-type Line struct {
-	ty  LineType
-	lv  int
-	val []byte
-	dep int
+	doc, err := c.parseDocument(lines)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc.Meta = meta
+
+	return doc, all[:len(all)-len(lines)], nil
 }
 
+// Option configures a conversion
+type Option func(*converter)
+
+// WithHeadingAnchors adds a hover anchor link to each heading
+// rendered by the default HTMLRenderer
+func WithHeadingAnchors() Option {
+	return func(c *converter) {
+		c.anchors = true
+	}
+}
+
+// WithSoftBreaks joins consecutive lines of text into one paragraph
+// instead of making each line a paragraph. The front matter key
+// softbreaks overrides it for the document.
+func WithSoftBreaks() Option {
+	return func(c *converter) {
+		c.softBreaks = true
+	}
+}
+
+// converter holds the options and the document state of a conversion
+type converter struct {
+	anchors      bool
+	strict       bool
+	softBreaks   bool
+	wrap         int
+	noCode       bool
+	truncate     int
+	noColor      bool
+	r            Renderer
+	hooks        map[ast.Kind]NodeRenderFunc
+	blocks       []BlockParser
+	inlines      []InlineParser
+	transformers []Transformer
+	src          *source
+	err          error // first error found while parsing inline
+	fns          *footnotes
+	refs         *linkRefs
+	ids          map[string]bool
+	headings     []Heading
+}
+
+func newConverter(opts []Option) *converter {
+	c := &converter{
+		hooks: make(map[ast.Kind]NodeRenderFunc),
+		ids:   make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.r == nil {
+		c.r = HTMLRenderer{HeadingAnchors: c.anchors}
+	}
+	return c
+}
+
+func (c *converter) parseDocument(lines [][]byte) (*ast.Document, error) {
+	doc := &ast.Document{}
+	doc.SetSpan(c.src.span(c.src.buf))
+	lines, c.fns = collectFootnotes(lines)
+	lines, c.refs = collectLinkRefs(lines)
+	if err := c.parse(doc, lines); err != nil {
+		return nil, err
+	}
+	if err := c.fns.parse(c, doc); err != nil {
+		return nil, err
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return doc, nil
+}
+
+// parse markdown lines to block nodes
+
 // https://play.golang.org/p/igrR4P6blOD
 
 var (
 	headerExp     = regexp.MustCompile(`^(#){1,6} (.+)`)
 	blockquoteExp = regexp.MustCompile(`^(>+)(.+)`)
-	emphasisExp   = regexp.MustCompile(`.*([\*]([^\*]+)[\*]).*|.*([_]([^_]+)[_]).*`)
-	strongExp     = regexp.MustCompile(`.*([\*]{2}([^\*]+)[\*]{2}).*|.*([_]{2}([^_]+)[_]{2}).*`)
-	imageExp      = regexp.MustCompile(`^!.*(\[.+\])(\(.+\)).*`)
-	linkExp       = regexp.MustCompile(`.*(\[.+\])(\(.+\)).*`)
-	listExp       = regexp.MustCompile(`^ *(- )(.+)`)
-	codespanExp   = regexp.MustCompile("[^`]*`([^`]+)`[^`]*")
+	listExp       = regexp.MustCompile(`^ *([-*+] )(.+)`)
+	orderedExp    = regexp.MustCompile(`^ *(\d{1,9})[.)] (.+)`)
+	setextExp     = regexp.MustCompile(`^(=+|-+)[ \t]*$`)
 	codefenceExp  = regexp.MustCompile("^```(.*)")
 )
 
-var inCodeBlock = false
-
-func conv(line []byte) (Line, error) {
-	inCodeSpan := false
+// openList is a list which following list items may be added to
+type openList struct {
+	n   *ast.List
+	dep int
+}
 
-	// raw html
-	// TODO: support other html tags
-	if bytes.HasPrefix(line, []byte("<blockquote")) {
-		return Line{HTMLBlock, 0, line, 0}, nil
+// parse parses lines into blocks appended to parent
+func (c *converter) parse(parent ast.Node, lines [][]byte) error {
+	var code *ast.CodeBlock
+	var fence []byte
+	var block Block
+	var blockLines [2][]byte // first and last line of block
+	var lists []openList
+	var table *ast.Table
+	var align []string // of the columns of table
+	var para [][]byte  // lines of the open paragraph
+	closePara := func() {
+		if len(para) > 0 {
+			ast.AppendChild(parent, c.paragraph(para))
+			para = nil
+		}
 	}
+	for _, line := range lines {
+		if code != nil {
+			extend(code, c.src.span(line))
+			if codefenceExp.Match(line) {
+				code = nil
+				continue
+			}
+			code.Code = append(code.Code, escapeCode(line)...)
+			code.Code = newline(code.Code)
+			continue
+		}
 
-	// inline
-	for codespanExp.Match(line) {
-		loc := codespanExp.FindSubmatchIndex(line)
-		// This is `cs sample`.
-		// -> line[loc[2]:loc[3]] // cs sample
-		line = []byte(fmt.Sprintf(`%s<code>%s</code>%s`, line[:loc[2]-1], line[loc[2]:loc[3]], line[loc[3]+1:]))
-		inCodeSpan = true
-	}
+		if block != nil {
+			state := block.Continue(line)
+			if state == BlockContinue {
+				blockLines[1] = line
+				continue
+			}
+			if state == BlockClose {
+				blockLines[1] = line
+			}
+			if err := c.closeBlock(parent, block, blockLines); err != nil {
+				return err
+			}
+			block, lists = nil, nil
+			if state == BlockClose {
+				continue
+			}
+		}
 
-	if !inCodeSpan && !inCodeBlock {
-		for strongExp.Match(line) {
-			loc := strongExp.FindSubmatchIndex(line)
-			// This is *em* sample
-			// -> line[loc[2]:loc[3]] // **st**
-			// -> line[loc[4]:loc[5]] // st
-			// -> line[loc[6]:loc[7]] // __st__
-			// -> line[loc[8]:loc[9]] // em
-			s := loc[2]
-			c := loc[3]
-			ts := loc[4]
-			tc := loc[5]
-			if s == -1 && c == -1 && ts == -1 && tc == -1 {
-				s = loc[6]
-				c = loc[7]
-				ts = loc[8]
-				tc = loc[9]
+		if table != nil {
+			if len(line) > 0 && bytes.IndexByte(line, '|') >= 0 {
+				c.tableRow(table, line, align, false)
+				continue
 			}
-			bef := []byte(fmt.Sprintf(`%s`, line[loc[0]:s]))
-			target := []byte(fmt.Sprintf(`<strong>%s</strong>`, line[ts:tc]))
-			aft := []byte(fmt.Sprintf(`%s`, line[c:]))
+			table = nil
+		}
+
+		if len(line) == 0 {
+			closePara()
+			continue
+		}
 
-			line = append(bef, append(target, aft...)...)
+		if len(para) > 0 {
+			// | header |
+			// | ------ |
+			if t := c.table(para[len(para)-1], line); t != nil {
+				para = para[:len(para)-1]
+				closePara()
+				ast.AppendChild(parent, t)
+				table, align, lists = t, tableAlign(t), nil
+				continue
+			}
 		}
 
-		for emphasisExp.Match(line) {
-			loc := emphasisExp.FindSubmatchIndex(line)
-			// This is *em* sample
-			// -> line[loc[2]:loc[3]] // *em*
-			// -> line[loc[4]:loc[5]] // em
-			// -> line[loc[6]:loc[7]] // _em_
-			// -> line[loc[8]:loc[9]] // em
-			s := loc[2]
-			c := loc[3]
-			ts := loc[4]
-			tc := loc[5]
-			if s == -1 && c == -1 && ts == -1 && tc == -1 {
-				s = loc[6]
-				c = loc[7]
-				ts = loc[8]
-				tc = loc[9]
+		if len(para) > 0 && setextExp.Match(line) {
+			// Header1
+			// =======
+			level := 1
+			if line[0] == '-' {
+				level = 2
 			}
-			bef := []byte(fmt.Sprintf(`%s`, line[loc[0]:s]))
-			target := []byte(fmt.Sprintf(`<em>%s</em>`, line[ts:tc]))
-			aft := []byte(fmt.Sprintf(`%s`, line[c:]))
+			h := c.heading(level, c.src.join(para))
+			h.SetSpan(c.src.spanOf(para[0], line))
+			ast.AppendChild(parent, h)
+			para = nil
+			continue
+		}
 
-			line = append(bef, append(target, aft...)...)
+		if b, ok := c.openBlock(line); ok {
+			closePara()
+			block = b
+			blockLines = [2][]byte{line, line}
+			continue
 		}
 
-		for imageExp.Match(line) {
-			loc := linkExp.FindSubmatchIndex(line)
-			// ![image](/path/to/image)
-			// -> line[loc[2]:loc[3]] // [image]
-			// -> line[loc[4]:loc[5]] // (/path/to/image)
-			line = []byte(fmt.Sprintf(`<img src="%s" alt="%s" />`, line[loc[4]+1:loc[5]-1], line[loc[2]+1:loc[3]-1]))
+		if listExp.Match(line) {
+			closePara()
+			loc := listExp.FindSubmatchIndex(line)
+			// - list
+			// -> line[loc[4]:loc[5]] // list
+			item := &ast.ListItem{}
+			item.SetSpan(c.src.span(line))
+			c.parseInline(item, line[loc[4]:loc[5]])
+			lists = appendListItem(parent, lists, item, loc[2]/2, false, 0)
+			continue
 		}
 
-		for linkExp.Match(line) {
-			loc := linkExp.FindSubmatchIndex(line)
-			// This is [link](https://example.org/)
-			// -> line[loc[2]:loc[3]] // [link]
-			// -> line[loc[4]:loc[5]] // (https://example.org/)
-			bef := []byte(fmt.Sprintf(`%s`, line[loc[0]:loc[2]]))
-			target := []byte(fmt.Sprintf(`<a href="%s">%s</a>`, line[loc[4]+1:loc[5]-1], line[loc[2]+1:loc[3]-1]))
-			aft := []byte(fmt.Sprintf(`%s`, line[loc[5]:]))
+		if orderedExp.Match(line) {
+			closePara()
+			loc := orderedExp.FindSubmatchIndex(line)
+			// 1. list
+			// -> line[loc[2]:loc[3]] // 1
+			// -> line[loc[4]:loc[5]] // list
+			item := &ast.ListItem{}
+			item.SetSpan(c.src.span(line))
+			c.parseInline(item, line[loc[4]:loc[5]])
+			start, _ := strconv.Atoi(string(line[loc[2]:loc[3]]))
+			lists = appendListItem(parent, lists, item, loc[2]/2, true, start)
+			continue
+		}
 
-			line = append(bef, append(target, aft...)...)
+		n := c.parseLine(line)
+		lists = nil
+		if n == nil {
+			if !c.softBreaks {
+				closePara()
+			}
+			para = append(para, line)
+			continue
+		}
+		closePara()
+		n.SetSpan(c.src.span(line))
+		ast.AppendChild(parent, n)
+		if cb, ok := n.(*ast.CodeBlock); ok {
+			code, fence = cb, line
 		}
 	}
+	closePara()
+
+	if code != nil && c.strict {
+		return c.src.errorAt(fence, "unclosed code fence")
+	}
+	if block != nil {
+		return c.closeBlock(parent, block, blockLines)
+	}
+	return nil
+}
+
+// parseLine parses a line which is not a list item, or returns nil for a
+// line of a paragraph
+func (c *converter) parseLine(line []byte) ast.Node {
+	// raw html
+	// TODO: support other html tags
+	if bytes.HasPrefix(line, []byte("<blockquote")) {
+		return &ast.HTMLBlock{Raw: line}
+	}
+
+	if isTOC(line) {
+		return &ast.TOC{}
+	}
 
-	// block
 	if headerExp.Match(line) {
 		loc := headerExp.FindSubmatchIndex(line)
 		// ## Header2
 		// -> line[loc[0]:loc[3]] // ##
 		// -> line[loc[4]:loc[5]] // Header2
-		return Line{Header, loc[3], line[loc[4]:loc[5]], 0}, nil
+		return c.heading(loc[3], line[loc[4]:loc[5]])
 	}
 
 	if blockquoteExp.Match(line) {
@@ -167,125 +358,78 @@ func conv(line []byte) (Line, error) {
 		// > quote
 		// -> line[loc[0]:loc[3]] // >
 		// -> line[loc[4]:loc[4]] // quote
-		return Line{Blockquote, loc[3], line[loc[4]:loc[5]], 0}, nil
-	}
-
-	if listExp.Match(line) {
-		loc := listExp.FindSubmatchIndex(line)
-		// - list
-		// -> line[loc[4]:loc[5]] // list
-		return Line{List, 0, line[loc[4]:loc[5]], loc[2] / 2}, nil
+		bq := &ast.Blockquote{Level: loc[3]}
+		c.parseInline(bq, bytes.TrimSpace(line[loc[4]:loc[5]]))
+		return bq
 	}
 
 	if codefenceExp.Match(line) {
 		loc := codefenceExp.FindSubmatchIndex(line)
 		// ```go
 		// -> line[loc[2]:loc[3]] // go
-		var lang []byte
+		cb := &ast.CodeBlock{Code: make([]byte, 0)}
 		if loc[2] != loc[3] {
-			lang = line[loc[2]:loc[3]]
+			cb.Lang = line[loc[2]:loc[3]]
 		}
-		inCodeBlock = !inCodeBlock
-		return Line{CodeFence, 0, lang, 0}, nil
+		return cb
 	}
 
-	return Line{Paragraph, 0, line, 0}, nil
+	// paragraph
+	return nil
 }
 
-// render html from Line
-
-func render(lines []Line) []byte {
-	ret := make([]byte, 0)
-
-	inCodeFence := false
-	for idx, line := range lines {
-		// render html
-		switch line.ty {
-		case Header:
-			if inCodeFence {
-				ret = append(ret, []byte(fmt.Sprintf(`%s %s`, strings.Repeat("#", line.lv), line.val))...)
-			} else {
-				ret = append(ret, []byte(fmt.Sprintf(`<h%d>%s</h%d>`, line.lv, line.val, line.lv))...)
-			}
-
-		case Blockquote:
-			if inCodeFence {
-				ret = append(ret, []byte(fmt.Sprintf(`%s%s`, strings.Repeat("&gt;", line.lv), line.val))...)
-			} else {
-				var stag string
-				var ctag string
-				for i := 0; i < line.lv; i++ {
-					stag = fmt.Sprintf(`<blockquote>%s`, stag)
-					ctag = fmt.Sprintf(`%s</blockquote>`, ctag)
-				}
-				ret = append(ret, []byte(fmt.Sprintf(`%s<p>%s</p>%s`, stag, bytes.TrimSpace(line.val), ctag))...)
-			}
-
-		case List:
-			if inCodeFence {
-				ret = append(ret, []byte(fmt.Sprintf(`- %s`, line.val))...)
-			} else {
-				if (idx > 0 && lines[idx-1].ty != List) || idx == 0 {
-					ret = append(ret, []byte(`<ul>`)...)
-					ret = newline(ret)
-				}
-
-				if idx > 0 && lines[idx-1].dep < line.dep {
-					ret = append(ret, []byte(`<ul>`)...)
-					ret = newline(ret)
-				}
-
-				ret = append(ret, []byte(fmt.Sprintf(`<li>%s</li>`, line.val))...)
-
-				if idx < len(lines)-1 && line.dep > lines[idx+1].dep {
-					for d := line.dep - lines[idx+1].dep; d > 0; d-- {
-						ret = newline(ret)
-						ret = append(ret, []byte(`</ul>`)...)
-					}
-				}
-				if idx == len(lines)-1 && line.dep > 0 {
-					for d := line.dep; d > 0; d-- {
-						ret = newline(ret)
-						ret = append(ret, []byte(`</ul>`)...)
-					}
-				}
-
-				if (idx < len(lines)-1 && lines[idx+1].ty != List) || idx == len(lines)-1 {
-					ret = newline(ret)
-					ret = append(ret, []byte(`</ul>`)...)
-				}
-			}
-
-		case CodeFence:
-			if !inCodeFence {
-				if line.val != nil {
-					ret = append(ret, []byte(fmt.Sprintf(`<pre><code class="%s">`, line.val))...)
-				} else {
-					ret = append(ret, []byte(`<pre><code>`)...)
-				}
-			} else {
-				ret = append(ret, []byte(`</code></pre>`)...)
-			}
-			inCodeFence = !inCodeFence
-			continue
-
-		case HTMLBlock:
-			ret = append(ret, line.val...)
+// paragraph parses the lines of a paragraph
+func (c *converter) paragraph(lines [][]byte) *ast.Paragraph {
+	p := &ast.Paragraph{}
+	p.SetSpan(c.src.spanOf(lines[0], lines[len(lines)-1]))
+	c.parseInline(p, c.src.join(lines))
+	return p
+}
 
-		case Paragraph:
-			if !inCodeFence {
-				ret = append(ret, []byte(fmt.Sprintf(`<p>%s</p>`, line.val))...)
-			} else {
-				ret = append(ret, []byte(fmt.Sprintf(`%s`, line.val))...)
-			}
-		}
+func (c *converter) heading(level int, text []byte) *ast.Heading {
+	h := &ast.Heading{Level: level}
+	if m := headingIDExp.FindSubmatchIndex(text); m != nil {
+		// Header2 {#custom-id}
+		// -> text[m[2]:m[3]] // custom-id
+		h.ID = string(text[m[2]:m[3]])
+		text = text[:m[0]]
+	}
+	c.parseInline(h, text)
+	return h
+}
 
-		if idx != len(lines)-1 {
-			ret = newline(ret)
-		}
+// appendListItem adds item with depth dep to the open lists, creating
+// a nested list when it is deeper than the innermost one. An ordered list
+// starting at start is created for an ordered item, and switching between
+// ordered and unordered items starts a new list.
+func appendListItem(parent ast.Node, lists []openList, item *ast.ListItem, dep int, ordered bool, start int) []openList {
+	for len(lists) > 1 && lists[len(lists)-1].dep > dep {
+		lists = lists[:len(lists)-1]
+	}
+	if top := len(lists) - 1; top >= 0 && lists[top].dep >= dep && lists[top].n.Ordered != ordered {
+		lists = lists[:top]
+	}
+	if len(lists) == 0 {
+		l := &ast.List{Ordered: ordered, Start: start}
+		ast.AppendChild(parent, l)
+		lists = append(lists, openList{n: l})
 	}
+	if top := lists[len(lists)-1]; dep > top.dep {
+		l := &ast.List{Ordered: ordered, Start: start}
+		ast.AppendChild(top.n, l)
+		lists = append(lists, openList{n: l, dep: dep})
+	}
+	ast.AppendChild(lists[len(lists)-1].n, item)
+	for _, l := range lists {
+		extend(l.n, item.Span())
+	}
+	return lists
+}
 
-	return ret
+func escapeCode(line []byte) []byte {
+	line = bytes.Replace(line, []byte("&"), []byte("&amp;"), -1)
+	line = bytes.Replace(line, []byte("<"), []byte("&lt;"), -1)
+	return bytes.Replace(line, []byte(">"), []byte("&gt;"), -1)
 }
 
 func newline(ret []byte) []byte {
@@ -2,7 +2,14 @@ package gom2h
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"unicode/utf8"
+
+	"github.com/matsuyoshi30/gom2h/ast"
 )
 
 func TestEmphasis(t *testing.T) {
@@ -15,6 +22,8 @@ func TestEmphasis(t *testing.T) {
 		{`This is *multiple* *em* sample2.`, []byte(`<p>This is <em>multiple</em> <em>em</em> sample2.</p>`)},
 		{`This is _other_ em.`, []byte(`<p>This is <em>other</em> em.</p>`)},
 		{`This is _not* em.`, []byte(`<p>This is _not* em.</p>`)},
+		{`This is snake_case_word.`, []byte(`<p>This is snake_case_word.</p>`)},
+		{`This is *nested **strong** in* em.`, []byte(`<p>This is <em>nested <strong>strong</strong> in</em> em.</p>`)},
 	}
 
 	for _, tt := range testcases {
@@ -73,6 +82,32 @@ func TestEmphasisAndStrong(t *testing.T) {
 	}
 }
 
+func TestStrikethrough(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected []byte
+	}{
+		{`~~deleted~~`, []byte(`<p><del>deleted</del></p>`)},
+		{`This is ~deleted~ sample1.`, []byte(`<p>This is <del>deleted</del> sample1.</p>`)},
+		{`This is ~~multiple~~ ~~deleted~~ sample2.`, []byte(`<p>This is <del>multiple</del> <del>deleted</del> sample2.</p>`)},
+		{`~~**strong** in deleted~~`, []byte(`<p><del><strong>strong</strong> in deleted</del></p>`)},
+		{`*~~deleted~~ in em*`, []byte(`<p><em><del>deleted</del> in em</em></p>`)},
+		{`This is ~~not~ deleted.`, []byte(`<p>This is ~~not~ deleted.</p>`)},
+		{`This is ~~~not~~~ deleted.`, []byte(`<p>This is ~~~not~~~ deleted.</p>`)},
+		{`This is ~~ not~~ deleted.`, []byte(`<p>This is ~~ not~~ deleted.</p>`)},
+	}
+
+	for _, tt := range testcases {
+		actual, err := Run([]byte(tt.input))
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if !bytes.Equal(tt.expected, actual) {
+			t.Errorf("expected %v, but got %v\n", string(tt.expected), string(actual))
+		}
+	}
+}
+
 func TestLink(t *testing.T) {
 	testcases := []struct {
 		input    string
@@ -81,6 +116,9 @@ func TestLink(t *testing.T) {
 		{`![image](/path/to/image)`, []byte(`<p><img src="/path/to/image" alt="image" /></p>`)},
 		{`[link](https://example.org/)`, []byte(`<p><a href="https://example.org/">link</a></p>`)},
 		{`This is [link](https://example.org/) test.`, []byte(`<p>This is <a href="https://example.org/">link</a> test.</p>`)},
+		{"[link][Example] and [example][] and [example]\n\n[example]: https://example.org/", []byte(`<p><a href="https://example.org/">link</a> and <a href="https://example.org/">example</a> and <a href="https://example.org/">example</a></p>`)},
+		{"![image][img]\n[img]: /path/to/image", []byte(`<p><img src="/path/to/image" alt="image" /></p>`)},
+		{"[undefined] and [link][undefined]", []byte(`<p>[undefined] and [link][undefined]</p>`)},
 	}
 
 	for _, tt := range testcases {
@@ -99,14 +137,17 @@ func TestHeader(t *testing.T) {
 		input    string
 		expected []byte
 	}{
-		{`# Header1`, []byte(`<h1>Header1</h1>`)},
-		{`## Header2`, []byte(`<h2>Header2</h2>`)},
-		{`### Header3`, []byte(`<h3>Header3</h3>`)},
-		{`#### Header4`, []byte(`<h4>Header4</h4>`)},
-		{`##### Header5`, []byte(`<h5>Header5</h5>`)},
-		{`###### Header6`, []byte(`<h6>Header6</h6>`)},
+		{`# Header1`, []byte(`<h1 id="header1">Header1</h1>`)},
+		{`## Header2`, []byte(`<h2 id="header2">Header2</h2>`)},
+		{`### Header3`, []byte(`<h3 id="header3">Header3</h3>`)},
+		{`#### Header4`, []byte(`<h4 id="header4">Header4</h4>`)},
+		{`##### Header5`, []byte(`<h5 id="header5">Header5</h5>`)},
+		{`###### Header6`, []byte(`<h6 id="header6">Header6</h6>`)},
 		{`####### Header7`, []byte(`<p>####### Header7</p>`)}, // no header tag
-		{`# *em* header`, []byte(`<h1><em>em</em> header</h1>`)},
+		{`# *em* header`, []byte(`<h1 id="em-header"><em>em</em> header</h1>`)},
+		{"Header1\n===", []byte(`<h1 id="header1">Header1</h1>`)},
+		{"Header2 {#h2}\n---", []byte(`<h2 id="h2">Header2</h2>`)},
+		{"Paragraph\n\n---", []byte("<p>Paragraph</p>\n<p>---</p>")},
 	}
 
 	for _, tt := range testcases {
@@ -120,6 +161,66 @@ func TestHeader(t *testing.T) {
 	}
 }
 
+func TestHeadingID(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected []byte
+	}{
+		{`# Hello, World!`, []byte(`<h1 id="hello-world">Hello, World!</h1>`)},
+		{`## snake_case and kebab-case`, []byte(`<h2 id="snake_case-and-kebab-case">snake_case and kebab-case</h2>`)},
+		{`# こんにちは 世界`, []byte(`<h1 id="こんにちは-世界">こんにちは 世界</h1>`)},
+		{`# Ünïcödé Heading`, []byte(`<h1 id="ünïcödé-heading">Ünïcödé Heading</h1>`)},
+		{"# `code` heading", []byte(`<h1 id="code-heading"><code>code</code> heading</h1>`)},
+		{`# [link](https://example.org/) &amp; *em*`, []byte(`<h1 id="link--em"><a href="https://example.org/">link</a> &amp; <em>em</em></h1>`)},
+		{`## Custom {#my-id}`, []byte(`<h2 id="my-id">Custom</h2>`)},
+		{`# Same
+# Same
+## Same
+# Same-1`, []byte(`<h1 id="same">Same</h1>
+<h1 id="same-1">Same</h1>
+<h2 id="same-2">Same</h2>
+<h1 id="same-1-1">Same-1</h1>`)},
+		{`# Custom {#same}
+# Same`, []byte(`<h1 id="same">Custom</h1>
+<h1 id="same-1">Same</h1>`)},
+		{`# Hello, World!
+# Hello, World!
+## Hello World {#hello-world}`, []byte(`<h1 id="hello-world-1">Hello, World!</h1>
+<h1 id="hello-world-2">Hello, World!</h1>
+<h2 id="hello-world">Hello World</h2>`)},
+	}
+
+	for _, tt := range testcases {
+		actual, err := Run([]byte(tt.input))
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if !bytes.Equal(tt.expected, actual) {
+			t.Errorf("expected %v, but got %v\n", string(tt.expected), string(actual))
+		}
+	}
+}
+
+func TestHeadingAnchors(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected []byte
+	}{
+		{`# Header1`, []byte(`<h1 id="header1"><a class="anchor" href="#header1" aria-hidden="true"><span class="octicon octicon-link"></span></a>Header1</h1>`)},
+		{`## Custom {#my-id}`, []byte(`<h2 id="my-id"><a class="anchor" href="#my-id" aria-hidden="true"><span class="octicon octicon-link"></span></a>Custom</h2>`)},
+	}
+
+	for _, tt := range testcases {
+		actual, err := Run([]byte(tt.input), WithHeadingAnchors())
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if !bytes.Equal(tt.expected, actual) {
+			t.Errorf("expected %v, but got %v\n", string(tt.expected), string(actual))
+		}
+	}
+}
+
 func TestBlockquote(t *testing.T) {
 	testcases := []struct {
 		input    string
@@ -164,6 +265,29 @@ func TestList(t *testing.T) {
 <li>list2-1</li>
 </ul>
 <li>list3</li>
+</ul>`)},
+		{`* list1
++ list2`, []byte(`<ul>
+<li>list1</li>
+<li>list2</li>
+</ul>`)},
+		{`1. list1
+2) list2`, []byte(`<ol>
+<li>list1</li>
+<li>list2</li>
+</ol>`)},
+		{`3. list3
+   - list3-1
+4. list4
+- list5`, []byte(`<ol start="3">
+<li>list3</li>
+<ul>
+<li>list3-1</li>
+</ul>
+<li>list4</li>
+</ol>
+<ul>
+<li>list5</li>
 </ul>`)},
 	}
 
@@ -178,6 +302,58 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestTable(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected []byte
+	}{
+		{"| Name | Size |\n| --- | --- |\n| a | 1 |", []byte(`<table>
+<thead>
+<tr>
+<th>Name</th>
+<th>Size</th>
+</tr>
+</thead>
+<tbody>
+<tr>
+<td>a</td>
+<td>1</td>
+</tr>
+</tbody>
+</table>`)},
+		{"text\nName | `a|b` | *Size*\n:-- | :-: | --:\nx | y \\| z\nshort", []byte(`<p>text</p>
+<table>
+<thead>
+<tr>
+<th align="left">Name</th>
+<th align="center"><code>a|b</code></th>
+<th align="right"><em>Size</em></th>
+</tr>
+</thead>
+<tbody>
+<tr>
+<td align="left">x</td>
+<td align="center">y | z</td>
+<td align="right"></td>
+</tr>
+</tbody>
+</table>
+<p>short</p>`)},
+		{"| Name |\n| --- |", []byte("<table>\n<thead>\n<tr>\n<th>Name</th>\n</tr>\n</thead>\n</table>")},
+		{"a | b\n---", []byte(`<h2 id="a--b">a | b</h2>`)},
+	}
+
+	for _, tt := range testcases {
+		actual, err := Run([]byte(tt.input))
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if !bytes.Equal(tt.expected, actual) {
+			t.Errorf("expected %v, but got %v\n", string(tt.expected), string(actual))
+		}
+	}
+}
+
 func TestCodeSpan(t *testing.T) {
 	testcases := []struct {
 		input    string
@@ -272,3 +448,851 @@ func TestHTMLBlock(t *testing.T) {
 		}
 	}
 }
+
+func TestFootnote(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected []byte
+	}{
+		{`This is footnote[^1].
+
+[^1]: footnote text.`, []byte(`<p>This is footnote<sup id="fnref-1"><a href="#fn-1" class="footnote-ref">1</a></sup>.</p>
+<section class="footnotes">
+<ol>
+<li id="fn-1">
+<p>footnote text. <a href="#fnref-1" class="footnote-backref">&#8617;</a></p>
+</li>
+</ol>
+</section>`)},
+		{`[^b]: second.
+[^a]: first.
+
+First[^a] and second[^b] and first again[^a].`, []byte(`<p>First<sup id="fnref-1"><a href="#fn-1" class="footnote-ref">1</a></sup> and second<sup id="fnref-2"><a href="#fn-2" class="footnote-ref">2</a></sup> and first again<sup id="fnref-1-2"><a href="#fn-1" class="footnote-ref">1</a></sup>.</p>
+<section class="footnotes">
+<ol>
+<li id="fn-1">
+<p>first. <a href="#fnref-1" class="footnote-backref">&#8617;</a> <a href="#fnref-1-2" class="footnote-backref">&#8617;<sup>2</sup></a></p>
+</li>
+<li id="fn-2">
+<p>second. <a href="#fnref-2" class="footnote-backref">&#8617;</a></p>
+</li>
+</ol>
+</section>`)},
+		{`Multiple paragraphs[^note].
+
+[^note]: first paragraph.
+
+    second *paragraph*.
+
+    - list
+After definition.`, []byte(`<p>Multiple paragraphs<sup id="fnref-1"><a href="#fn-1" class="footnote-ref">1</a></sup>.</p>
+<p>After definition.</p>
+<section class="footnotes">
+<ol>
+<li id="fn-1">
+<p>first paragraph.</p>
+<p>second <em>paragraph</em>.</p>
+<ul>
+<li>list</li>
+</ul>
+<p><a href="#fnref-1" class="footnote-backref">&#8617;</a></p>
+</li>
+</ol>
+</section>`)},
+		{"Undefined[^1] and `[^2]` in code span.\n\n[^2]: unused.", []byte("<p>Undefined[^1] and <code>[^2]</code> in code span.</p>")},
+	}
+
+	for _, tt := range testcases {
+		actual, err := Run([]byte(tt.input))
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if !bytes.Equal(tt.expected, actual) {
+			t.Errorf("expected %v, but got %v\n", string(tt.expected), string(actual))
+		}
+	}
+}
+
+func TestTOC(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected []byte
+	}{
+		{`[TOC]
+# A
+## B
+## C
+# D`, []byte(`<ul>
+<li><a href="#a">A</a>
+<ul>
+<li><a href="#b">B</a></li>
+<li><a href="#c">C</a></li>
+</ul>
+</li>
+<li><a href="#d">D</a></li>
+</ul>
+<h1 id="a">A</h1>
+<h2 id="b">B</h2>
+<h2 id="c">C</h2>
+<h1 id="d">D</h1>`)},
+		{`## *A* & B
+<!-- toc -->
+#### C
+### D
+## E {#e-id}`, []byte(`<h2 id="a--b"><em>A</em> & B</h2>
+<ul>
+<li><a href="#a--b">A &amp; B</a>
+<ul>
+<li><a href="#c">C</a></li>
+<li><a href="#d">D</a></li>
+</ul>
+</li>
+<li><a href="#e-id">E</a></li>
+</ul>
+<h4 id="c">C</h4>
+<h3 id="d">D</h3>
+<h2 id="e-id">E</h2>`)},
+		{"```\n[TOC]\n```", []byte("<pre><code>[TOC]\n</code></pre>")},
+	}
+
+	for _, tt := range testcases {
+		actual, err := Run([]byte(tt.input))
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if !bytes.Equal(tt.expected, actual) {
+			t.Errorf("expected %v, but got %v\n", string(tt.expected), string(actual))
+		}
+	}
+}
+
+func TestHeadings(t *testing.T) {
+	input := `# Title
+## Section [^1]
+` + "```\n# not a heading\n```" + `
+[^1]: note
+    ## Footnote heading`
+	expected := []Heading{
+		{Level: 1, Text: "Title", ID: "title"},
+		{Level: 2, Text: "Section 1", ID: "section-1"},
+	}
+
+	res, err := Convert([]byte(input))
+	if err != nil {
+		t.Errorf("unexpected err: %v\n", err)
+	}
+	if len(res.Headings) != len(expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, res.Headings)
+	}
+	for i := range expected {
+		if expected[i] != res.Headings[i] {
+			t.Errorf("expected %v, but got %v\n", expected[i], res.Headings[i])
+		}
+	}
+}
+
+func TestWordCount(t *testing.T) {
+	input := "---\ntitle: not counted\n---\n# Hello world\n\nOne *two* three.\n\n```\ncode block\n```"
+	res, err := Convert([]byte(input))
+	if err != nil {
+		t.Errorf("unexpected err: %v\n", err)
+	}
+	if res.WordCount != 7 {
+		t.Errorf("expected 7 words, but got %d\n", res.WordCount)
+	}
+}
+
+func TestFrontMatter(t *testing.T) {
+	testcases := []struct {
+		input    string
+		meta     map[string]interface{}
+		expected []byte
+	}{
+		{`---
+title: "Design: gom2h" # comment
+draft: false
+weight: 10
+tags: [go, 'markdown']
+authors:
+  - name: alice
+    url: https://example.org/
+  - bob
+params:
+  ratio: 1.5
+  empty:
+---
+# Header1`, map[string]interface{}{
+			"title":  "Design: gom2h",
+			"draft":  false,
+			"weight": 10,
+			"tags":   []interface{}{"go", "markdown"},
+			"authors": []interface{}{
+				map[string]interface{}{"name": "alice", "url": "https://example.org/"},
+				"bob",
+			},
+			"params": map[string]interface{}{"ratio": 1.5, "empty": nil},
+		}, []byte(`<h1 id="header1">Header1</h1>`)},
+		{`+++
+title = 'Design'
+date = 2021-07-10
+tags = ["go", "markdown",]
+[params]
+count = 1_000
+author.name = "alice"
++++
+
+paragraph`, map[string]interface{}{
+			"title": "Design",
+			"date":  "2021-07-10",
+			"tags":  []interface{}{"go", "markdown"},
+			"params": map[string]interface{}{
+				"count":  1000,
+				"author": map[string]interface{}{"name": "alice"},
+			},
+		}, []byte(`<p>paragraph</p>`)},
+		{`---
+no closing delimiter`, nil, []byte(`<p>---</p>
+<p>no closing delimiter</p>`)},
+	}
+
+	for _, tt := range testcases {
+		actual, err := Convert([]byte(tt.input))
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.meta, actual.Meta) {
+			t.Errorf("expected %v, but got %v\n", tt.meta, actual.Meta)
+		}
+		if !bytes.Equal(tt.expected, actual.HTML) {
+			t.Errorf("expected %v, but got %v\n", string(tt.expected), string(actual.HTML))
+		}
+	}
+}
+
+func TestFrontMatterError(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected ParseError
+	}{
+		{"---\ntitle: ok\n  nested: ng\n---", ParseError{Line: 3, Col: 3, Msg: "front matter: unexpected indentation"}},
+		{"---\njust text\n---", ParseError{Line: 2, Col: 1, Msg: "front matter: expected key: value"}},
+		{"+++\ntitle = unquoted\n+++", ParseError{Line: 2, Col: 1, Msg: "front matter: invalid value unquoted"}},
+		{"+++\ntitle = \"a\"\n[title]\n+++", ParseError{Line: 3, Col: 1, Msg: `front matter: "title" is not a table`}},
+	}
+
+	for _, tt := range testcases {
+		_, err := Convert([]byte(tt.input), WithStrict())
+		perr, ok := err.(*ParseError)
+		if !ok || *perr != tt.expected {
+			t.Errorf("expected %v, but got %v\n", &tt.expected, err)
+		}
+	}
+}
+
+func TestFrontMatterFallback(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected []byte
+	}{
+		{"---\nThis is a note.\n---\n\nHello\n", []byte("<p>---</p>\n<h2 id=\"this-is-a-note\">This is a note.</h2>\n<p>Hello</p>")},
+		{"+++\ntitle = unquoted\n+++", []byte("<p>+++</p>\n<p>title = unquoted</p>\n<p>+++</p>")},
+	}
+
+	for _, tt := range testcases {
+		res, err := Convert([]byte(tt.input))
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+			continue
+		}
+		if !bytes.Equal(tt.expected, res.HTML) {
+			t.Errorf("expected %v, but got %v\n", string(tt.expected), string(res.HTML))
+		}
+		if res.Meta != nil {
+			t.Errorf("expected no front matter, but got %v\n", res.Meta)
+		}
+	}
+}
+
+type testRenderer struct {
+	HTMLRenderer
+}
+
+func (testRenderer) Image(src, alt []byte) []byte {
+	return []byte(fmt.Sprintf(`<img src="%s" alt="%s" loading="lazy" />`, src, alt))
+}
+
+func (r testRenderer) CodeBlock(lang, code []byte) []byte {
+	return []byte(fmt.Sprintf(`<div class="code">%s</div>`, r.HTMLRenderer.CodeBlock(lang, code)))
+}
+
+func (r testRenderer) Table(header, body []byte) []byte {
+	return []byte(fmt.Sprintf(`<div class="scroll">%s</div>`, r.HTMLRenderer.Table(header, body)))
+}
+
+func TestRenderer(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected []byte
+	}{
+		{`![image](/path/to/image)`, []byte(`<p><img src="/path/to/image" alt="image" loading="lazy" /></p>`)},
+		{"```go" + `
+fmt.Println("Hello world")
+` + "```", []byte(`<div class="code"><pre><code class="go">fmt.Println("Hello world")
+</code></pre></div>`)},
+		{`# Header1
+- list1
+  - list1-1
+    - list1-1-1
+- list2`, []byte(`<h1 id="header1">Header1</h1>
+<ul>
+<li>list1</li>
+<ul>
+<li>list1-1</li>
+<ul>
+<li>list1-1-1</li>
+</ul>
+</ul>
+<li>list2</li>
+</ul>`)},
+		{"| a |\n| - |", []byte("<div class=\"scroll\"><table>\n<thead>\n<tr>\n<th>a</th>\n</tr>\n</thead>\n</table></div>")},
+	}
+
+	for _, tt := range testcases {
+		actual, err := Run([]byte(tt.input), WithRenderer(testRenderer{}))
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if !bytes.Equal(tt.expected, actual) {
+			t.Errorf("expected %v, but got %v\n", string(tt.expected), string(actual))
+		}
+	}
+}
+
+// :::kind ... ::: container
+type testContainerParser struct{}
+
+func (testContainerParser) Trigger() []byte { return []byte(":") }
+
+func (testContainerParser) Open(line []byte) (Block, bool) {
+	if !bytes.HasPrefix(line, []byte(":::")) || len(line) == 3 {
+		return nil, false
+	}
+	return &testContainer{kind: line[3:]}, true
+}
+
+type testContainer struct {
+	kind  []byte
+	lines [][]byte
+}
+
+func (b *testContainer) Continue(line []byte) BlockState {
+	if bytes.Equal(line, []byte(":::")) {
+		return BlockClose
+	}
+	b.lines = append(b.lines, line)
+	return BlockContinue
+}
+
+var testContainerKind = ast.NewBlockKind("TestContainer")
+
+type testContainerNode struct {
+	ast.BaseNode
+	kind []byte
+}
+
+func (n *testContainerNode) Kind() ast.Kind { return testContainerKind }
+
+func (b *testContainer) Close(parse func(ast.Node, [][]byte) error) (ast.Node, error) {
+	n := &testContainerNode{kind: b.kind}
+	if err := parse(n, b.lines); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func renderTestContainer(r Renderer, n ast.Node, children []byte) []byte {
+	return []byte(fmt.Sprintf("<div class=\"%s\">\n%s\n</div>", n.(*testContainerNode).kind, children))
+}
+
+// {{jira:KEY}} link
+type testJiraParser struct{}
+
+func (testJiraParser) Trigger() []byte { return []byte("{") }
+
+func (testJiraParser) Parse(line []byte) (ast.Node, int) {
+	if !bytes.HasPrefix(line, []byte("{{jira:")) {
+		return nil, 0
+	}
+	end := bytes.Index(line, []byte("}}"))
+	if end < 0 {
+		return nil, 0
+	}
+	key := line[len("{{jira:"):end]
+	l := &ast.Link{Dest: []byte("https://jira.example.org/browse/" + string(key))}
+	ast.AppendChild(l, &ast.Text{Value: key})
+	return l, end + 2
+}
+
+func TestExtension(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected []byte
+	}{
+		{`See {{jira:PROJ-123}} and {{jira:PROJ-4}}.`, []byte(`<p>See <a href="https://jira.example.org/browse/PROJ-123">PROJ-123</a> and <a href="https://jira.example.org/browse/PROJ-4">PROJ-4</a>.</p>`)},
+		{`Not {{jira:closed`, []byte(`<p>Not {{jira:closed</p>`)},
+		{`:::warning
+**Careful** with {{jira:PROJ-1}}
+
+- list1
+:::
+after`, []byte(`<div class="warning">
+<p><strong>Careful</strong> with <a href="https://jira.example.org/browse/PROJ-1">PROJ-1</a></p>
+<ul>
+<li>list1</li>
+</ul>
+</div>
+<p>after</p>`)},
+		{`:::note
+unclosed`, []byte(`<div class="note">
+<p>unclosed</p>
+</div>`)},
+		{"```\n:::warning\n```", []byte("<pre><code>:::warning\n</code></pre>")},
+	}
+
+	for _, tt := range testcases {
+		actual, err := Run([]byte(tt.input), WithBlockParser(testContainerParser{}), WithInlineParser(testJiraParser{}), WithNodeRenderer(testContainerKind, renderTestContainer))
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if !bytes.Equal(tt.expected, actual) {
+			t.Errorf("expected %v, but got %v\n", string(tt.expected), string(actual))
+		}
+	}
+}
+
+func TestTransformer(t *testing.T) {
+	rewriteLinks := TransformerFunc(func(doc *ast.Document) error {
+		ast.Walk(doc, func(n ast.Node, entering bool) ast.WalkStatus {
+			if l, ok := n.(*ast.Link); ok && entering {
+				l.Dest = bytes.Replace(l.Dest, []byte(".md"), []byte(".html"), 1)
+			}
+			return ast.WalkContinue
+		})
+		return nil
+	})
+	// removes the "Internal" section
+	stripSections := TransformerFunc(func(doc *ast.Document) error {
+		strip := false
+		for _, n := range ast.Children(doc) {
+			if h, ok := n.(*ast.Heading); ok {
+				strip = plainText(h) == "Internal"
+			}
+			if strip {
+				ast.Remove(n)
+			}
+		}
+		return nil
+	})
+	appendHeading := TransformerFunc(func(doc *ast.Document) error {
+		h := &ast.Heading{Level: 2}
+		ast.AppendChild(h, &ast.Text{Value: []byte("Appendix")})
+		ast.AppendChild(doc, h)
+		return nil
+	})
+
+	input := `[TOC]
+## Usage
+See [guide](guide.md).
+## Internal
+secret
+## Usage`
+	expected := []byte(`<ul>
+<li><a href="#usage">Usage</a></li>
+<li><a href="#usage-1">Usage</a></li>
+<li><a href="#appendix">Appendix</a></li>
+</ul>
+<h2 id="usage">Usage</h2>
+<p>See <a href="guide.html">guide</a>.</p>
+<h2 id="usage-1">Usage</h2>
+<h2 id="appendix">Appendix</h2>`)
+
+	actual, err := Run([]byte(input), WithTransformer(rewriteLinks), WithTransformer(stripSections), WithTransformer(appendHeading))
+	if err != nil {
+		t.Errorf("unexpected err: %v\n", err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("expected %v, but got %v\n", string(expected), string(actual))
+	}
+
+	failing := TransformerFunc(func(doc *ast.Document) error {
+		return fmt.Errorf("failed")
+	})
+	if _, err := Run([]byte(input), WithTransformer(failing)); err == nil || err.Error() != "failed" {
+		t.Errorf("expected failed, but got %v\n", err)
+	}
+}
+
+func TestURLResolver(t *testing.T) {
+	resolver := func(n ast.Node, dest string) string {
+		if n.Kind() == ast.KindImage {
+			return "/static/" + dest
+		}
+		return string(bytes.Replace([]byte(dest), []byte(".md"), []byte(".html"), 1))
+	}
+
+	testcases := []struct {
+		input    string
+		expected []byte
+	}{
+		{"[setup](setup.md#install)", []byte(`<p><a href="setup.html#install">setup</a></p>`)},
+		{"![logo](logo.png)", []byte(`<p><img src="/static/logo.png" alt="logo" /></p>`)},
+		{"[setup][s]\n\n[s]: setup.md", []byte(`<p><a href="setup.html">setup</a></p>`)},
+	}
+
+	for _, tt := range testcases {
+		actual, err := Run([]byte(tt.input), WithURLResolver(resolver))
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if !bytes.Equal(tt.expected, actual) {
+			t.Errorf("expected %v, but got %v\n", string(tt.expected), string(actual))
+		}
+	}
+}
+
+func TestSpan(t *testing.T) {
+	input := "\n# Head *em*\n\n- a\n  - b `c`\n" + "```" + `
+code
+` + "```"
+	type span struct {
+		kind               ast.Kind
+		startLine, endLine int
+		startCol, endCol   int
+	}
+	expected := []span{
+		{ast.KindDocument, 1, 8, 1, 4},
+		{ast.KindHeading, 2, 2, 1, 12},
+		{ast.KindText, 2, 2, 3, 8},
+		{ast.KindEmphasis, 2, 2, 8, 12},
+		{ast.KindText, 2, 2, 9, 11},
+		{ast.KindList, 4, 5, 1, 10},
+		{ast.KindListItem, 4, 4, 1, 4},
+		{ast.KindText, 4, 4, 3, 4},
+		{ast.KindList, 5, 5, 1, 10},
+		{ast.KindListItem, 5, 5, 1, 10},
+		{ast.KindText, 5, 5, 5, 7},
+		{ast.KindCodeSpan, 5, 5, 7, 10},
+		{ast.KindCodeBlock, 6, 8, 1, 4},
+	}
+
+	actual := make([]span, 0)
+	tr := TransformerFunc(func(doc *ast.Document) error {
+		ast.Walk(doc, func(n ast.Node, entering bool) ast.WalkStatus {
+			if entering {
+				s := n.Span()
+				actual = append(actual, span{n.Kind(), s.Start.Line, s.End.Line, s.Start.Col, s.End.Col})
+			}
+			return ast.WalkContinue
+		})
+		return nil
+	})
+	if _, err := Run([]byte(input), WithTransformer(tr)); err != nil {
+		t.Errorf("unexpected err: %v\n", err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v, but got %v\n", expected, actual)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected *ParseError
+	}{
+		{"# Header1\n\n```go\nfmt.Println()", &ParseError{Line: 3, Col: 1, Msg: "unclosed code fence"}},
+		{"# Header1\n\nsee [^note] and [^1].\n\n[^1]: footnote.", &ParseError{Line: 3, Col: 5, Msg: "undefined footnote note"}},
+		{"```\n[^note]\n```\n`[^note]`", nil},
+	}
+
+	for _, tt := range testcases {
+		if _, err := Run([]byte(tt.input)); err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+
+		_, err := Run([]byte(tt.input), WithStrict())
+		if tt.expected == nil {
+			if err != nil {
+				t.Errorf("unexpected err: %v\n", err)
+			}
+			continue
+		}
+		perr, ok := err.(*ParseError)
+		if !ok || *perr != *tt.expected {
+			t.Errorf("expected %v, but got %v\n", tt.expected, err)
+		}
+	}
+}
+
+func TestSoftBreaks(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected []byte
+	}{
+		{"line1\nline2", []byte("<p>line1\nline2</p>")},
+		{"line1\n\nline2", []byte("<p>line1</p>\n<p>line2</p>")},
+		{"*em\nphasis*\n# Header1", []byte("<p><em>em\nphasis</em></p>\n<h1 id=\"header1\">Header1</h1>")},
+		{"Header1\nline2\n===", []byte("<h1 id=\"header1-line2\">Header1\nline2</h1>")},
+		{"note[^1]\n\n[^1]: one\n    two\n\n    three\n\nafter", []byte("<p>note<sup id=\"fnref-1\"><a href=\"#fn-1\" class=\"footnote-ref\">1</a></sup></p>\n<p>after</p>\n<section class=\"footnotes\">\n<ol>\n<li id=\"fn-1\">\n<p>one\ntwo</p>\n<p>three <a href=\"#fnref-1\" class=\"footnote-backref\">&#8617;</a></p>\n</li>\n</ol>\n</section>")},
+	}
+
+	for _, tt := range testcases {
+		actual, err := Run([]byte(tt.input), WithSoftBreaks())
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if !bytes.Equal(tt.expected, actual) {
+			t.Errorf("expected %v, but got %v\n", string(tt.expected), string(actual))
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	testcases := []struct {
+		input    string
+		opts     []Option
+		expected string
+	}{
+		{"Header1\n===\nparagraph", nil, "# Header1\n\nparagraph\n"},
+		{"## Header2 {#custom}\n>quote", nil, "## Header2 {#custom}\n\n> quote\n"},
+		{"* list1\n    + list1-1\n* list2", nil, "- list1\n  - list1-1\n- list2\n"},
+		{"_em_ *em* __strong__ ~del~ ``a`b``", nil, "_em_ *em* __strong__ ~~del~~ ``a`b``\n"},
+		{"```go\nif a < b {\n\n}", nil, "```go\nif a < b {\n\n}\n```\n"},
+		{"[a][B] and [b]\n\n[unused]: /u\n[b]: /b\n\ntext", nil, "[a][B] and [b][]\n\ntext\n\n[b]: /b\n[unused]: /u\n"},
+		{"note[^b] and[^a]\n[^z]: unused\n[^a]: a\n    more\n[^b]: b", nil, "note[^b] and[^a]\n\n[^b]: b\n\n[^a]: a\n\n    more\n\n[^z]: unused\n"},
+		{"---\ntitle: t\n---\n# Header1", nil, "---\ntitle: t\n---\n\n# Header1\n"},
+		{"3) three\n  - sub\n7. four", nil, "3. three\n  - sub\n4. four\n"},
+		{"Name|Size\n:-|-:\n`a|b`|y \\| z\nlong cell|", nil, "| Name      | Size   |\n| :-------- | -----: |\n| `a|b`     | y \\| z |\n| long cell |        |\n"},
+		{"one two three four five six `a b`", []Option{WithSoftBreaks(), WithWrap(10)}, "one two\nthree four\nfive six\n`a b`\n"},
+		{"one two - three", []Option{WithSoftBreaks(), WithWrap(8)}, "one two -\nthree\n"},
+		{"one two three\nfour", []Option{WithWrap(8)}, "one two three\n\nfour\n"},
+		{"---\nsoftbreaks: true\n---\none two\nthree", []Option{WithWrap(8)}, "---\nsoftbreaks: true\n---\n\none two\nthree\n"},
+		{"one\ntwo *em\nphasis*\n\nthree", []Option{WithSoftBreaks()}, "one two *em phasis*\n\nthree\n"},
+	}
+
+	for _, tt := range testcases {
+		actual, err := Format([]byte(tt.input), tt.opts...)
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if string(actual) != tt.expected {
+			t.Errorf("expected %q, but got %q\n", tt.expected, actual)
+		}
+
+		again, err := Format(actual, tt.opts...)
+		if err != nil || !bytes.Equal(actual, again) {
+			t.Errorf("expected %q to be formatted, but got %q\n", actual, again)
+		}
+		if tt.opts != nil {
+			continue
+		}
+		expected, _ := Run([]byte(tt.input))
+		if html, _ := Run(actual); !bytes.Equal(expected, html) {
+			t.Errorf("expected %q, but got %q\n", expected, html)
+		}
+	}
+}
+
+func TestFormatRendering(t *testing.T) {
+	inputs := []string{
+		"one two three four five six seven\n1. one\n2. two\nsee | and - here\n| a | b |\n| - | - |\n| c | d |",
+		"---\nsoftbreaks: true\n---\none two three 1. four | five\n:-- six\nseven - eight = nine\n\n| a | b |\n| - | - |",
+		"a b c d e f g h i j k l m n o p\nq r s t\n- item one two three\n> quote one two three",
+	}
+
+	// soft breaks are written as a newline or a space
+	normalize := func(b []byte) string {
+		return strings.Join(strings.Fields(string(b)), " ")
+	}
+
+	for _, input := range inputs {
+		expected, err := Run([]byte(input))
+		if err != nil {
+			t.Fatalf("unexpected err: %v\n", err)
+		}
+		for _, width := range []int{1, 8, 20, 80} {
+			formatted, err := Format([]byte(input), WithWrap(width))
+			if err != nil {
+				t.Fatalf("unexpected err: %v\n", err)
+			}
+			actual, err := Run(formatted)
+			if err != nil {
+				t.Fatalf("unexpected err: %v\n", err)
+			}
+			if normalize(actual) != normalize(expected) {
+				t.Errorf("expected %q wrapped at %d to render %q, but got %q\n", input, width, expected, actual)
+			}
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	testcases := []struct {
+		input    string
+		opts     []Option
+		expected string
+	}{
+		{"# Header1\nThis is *em* and [link](https://example.org/) `code`.", nil, "Header1\n\nThis is em and link code."},
+		{"- list1\n  - list1-1\n- list2\n>quote", nil, "• list1\n  • list1-1\n• list2\n\nquote"},
+		{"![image](/path) &amp; <b>bold</b>", nil, "image & bold"},
+		{"text\n```go\na < b\n```\n[TOC]", nil, "text\n\na < b"},
+		{"text\n```go\na < b\n```", []Option{WithoutCodeBlocks()}, "text"},
+		{"note[^1]\n\n[^1]: footnote.", nil, "note[1]\n\n[1] footnote."},
+		{"2. two\n3. three\n\n| a | b |\n| - | - |\n| *x* | y |", nil, "2. two\n3. three\n\na\tb\nx\ty"},
+		{"one two three four", []Option{WithTruncate(9)}, "one two…"},
+		{"one two three four", []Option{WithTruncate(7)}, "one…"},
+		{"one two three four", []Option{WithTruncate(8)}, "one two…"},
+		{"onetwothree", []Option{WithTruncate(6)}, "onetw…"},
+		{"one two", []Option{WithTruncate(7)}, "one two"},
+	}
+
+	for _, tt := range testcases {
+		actual, err := PlainText([]byte(tt.input), tt.opts...)
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if string(actual) != tt.expected {
+			t.Errorf("expected %q, but got %q\n", tt.expected, actual)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	inputs := []string{"one two three four", "onetwothree", "日本語の 文章です"}
+	for _, input := range inputs {
+		for n := 1; n <= utf8.RuneCountInString(input)+1; n++ {
+			actual, err := PlainText([]byte(input), WithTruncate(n))
+			if err != nil {
+				t.Errorf("unexpected err: %v\n", err)
+			}
+			if size := utf8.RuneCountInString(string(actual)); size > n {
+				t.Errorf("expected %q truncated to %d characters, but got %q with %d\n", input, n, actual, size)
+			}
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected string
+	}{
+		{"# H1", `{"version":1,"document":{"type":"Document","span":{"start":{"offset":0,"line":1,"col":1},"end":{"offset":4,"line":1,"col":5}},"children":[` +
+			`{"type":"Heading","attrs":{"id":"h1","level":1},"span":{"start":{"offset":0,"line":1,"col":1},"end":{"offset":4,"line":1,"col":5}},"children":[` +
+			`{"type":"Text","attrs":{"value":"H1"},"span":{"start":{"offset":2,"line":1,"col":3},"end":{"offset":4,"line":1,"col":5}}}]}]}}`},
+		{"+++\nn = 1\n+++\n```go\na<b\n```", `{"version":1,"document":{"type":"Document","attrs":{"meta":{"n":1}},"span":{"start":{"offset":0,"line":1,"col":1},"end":{"offset":27,"line":6,"col":4}},"children":[` +
+			`{"type":"CodeBlock","attrs":{"code":"a\u003cb\n","lang":"go"},"span":{"start":{"offset":14,"line":4,"col":1},"end":{"offset":27,"line":6,"col":4}}}]}}`},
+		{"| a |\n| -: |", `{"version":1,"document":{"type":"Document","span":{"start":{"offset":0,"line":1,"col":1},"end":{"offset":12,"line":2,"col":7}},"children":[` +
+			`{"type":"Table","span":{"start":{"offset":0,"line":1,"col":1},"end":{"offset":12,"line":2,"col":7}},"children":[` +
+			`{"type":"TableRow","attrs":{"header":true},"span":{"start":{"offset":0,"line":1,"col":1},"end":{"offset":5,"line":1,"col":6}},"children":[` +
+			`{"type":"TableCell","attrs":{"align":"right"},"span":{"start":{"offset":2,"line":1,"col":3},"end":{"offset":3,"line":1,"col":4}},"children":[` +
+			`{"type":"Text","attrs":{"value":"a"},"span":{"start":{"offset":2,"line":1,"col":3},"end":{"offset":3,"line":1,"col":4}}}]}]}]}]}}`},
+	}
+
+	for _, tt := range testcases {
+		actual, err := JSON([]byte(tt.input))
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		var b bytes.Buffer
+		if err := json.Compact(&b, actual); err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if b.String() != tt.expected {
+			t.Errorf("expected %v, but got %v\n", tt.expected, b.String())
+		}
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	testcases := []struct {
+		input    string
+		opts     []Option
+		expected string
+	}{
+		{"# Header1\nThis is *em* and **strong**", nil, "\x1b[1;36m# Header1\x1b[22;39m\n\nThis is \x1b[3mem\x1b[23m and \x1b[1mstrong\x1b[22m\n"},
+		{"[link](https://example.org/) `code`\n```\ncode\n```", nil, "\x1b[4mlink\x1b[24m \x1b[2m(https://example.org/)\x1b[22m \x1b[33mcode\x1b[39m\n\n  \x1b[2mcode\x1b[22m\n"},
+		{"one two three four\n- item one two\n  - nested\n>> quote one two", []Option{WithWrap(10), WithoutColor()}, "one two\nthree four\n\n• item one\n  two\n  • nested\n\n│ │ quote\n│ │ one\n│ │ two\n"},
+		{"note[^1]\n\n[^1]: foot note", []Option{WithoutColor()}, "note[1]\n\n────────\n[1] foot note\n"},
+		{"9. item one two\n10. ten", []Option{WithWrap(10), WithoutColor()}, "9. item\n   one two\n10. ten\n"},
+		{"| Name | Size | Kind |\n| :--- | ---: | :-: |\n| *a* | 1 | x |\n| long name | 100 |", []Option{WithoutColor()},
+			"┌───────────┬──────┬──────┐\n│ Name      │ Size │ Kind │\n├───────────┼──────┼──────┤\n│ a         │    1 │  x   │\n│ long name │  100 │      │\n└───────────┴──────┴──────┘\n"},
+		{"| a |\n| - |", nil, "\x1b[2m┌───┐\x1b[22m\n\x1b[2m│\x1b[22m \x1b[1ma\x1b[22m \x1b[2m│\x1b[22m\n\x1b[2m└───┘\x1b[22m\n"},
+	}
+
+	for _, tt := range testcases {
+		actual, err := Terminal([]byte(tt.input), tt.opts...)
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if string(actual) != tt.expected {
+			t.Errorf("expected %q, but got %q\n", tt.expected, actual)
+		}
+	}
+}
+
+func TestMan(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected string
+	}{
+		{"---\ntitle: tool\nsection: 8\ndate: 2021-01-02\nsource: tool 1.0\nmanual: Tool Manual\n---\n# Name\ntool - do *things*",
+			".TH \"TOOL\" \"8\" \"2021\\-01\\-02\" \"tool 1.0\" \"Tool Manual\"\n.SH \"NAME\"\n.PP\ntool \\- do \\fIthings\\fP\n"},
+		{"# Tool\n## Options\n- `-a` **all**\n  - nested\n.dot",
+			".TH \"TOOL\" \"1\" \"\" \"\" \"\"\n.SH \"TOOL\"\n.SS \"Options\"\n.IP \\(bu 2\n\\fB\\-a\\fP \\fBall\\fP\n.RS 2\n.IP \\(bu 2\nnested\n.RE\n.PP\n\\&.dot\n"},
+		{"```sh\n$ tool \\\n'x\n```\n> [site](https://example.org)",
+			".TH \"\" \"1\" \"\" \"\" \"\"\n.PP\n.RS 4\n.nf\n$ tool \\e\n\\&'x\n.fi\n.RE\n.RS 4\n.PP\nsite \\(lahttps://example.org\\(ra\n.RE\n"},
+		{"2. two\n3. three", ".TH \"\" \"1\" \"\" \"\" \"\"\n.IP 2. 4\ntwo\n.IP 3. 4\nthree\n"},
+		{"| Flag | Default | Kind |\n| :--- | ---: | :-: |\n| `-a` | _ | a \\| b |\n| .x |",
+			"'\\\" t\n.TH \"\" \"1\" \"\" \"\" \"\"\n.PP\n.TS\nallbox;\nlb rb cb\nl r c.\nFlag\tDefault\tKind\n\\fB\\-a\\fP\t\\&_\ta | b\n\\&.x\t\t\n.TE\n"},
+	}
+
+	for _, tt := range testcases {
+		actual, err := Man([]byte(tt.input))
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if string(actual) != tt.expected {
+			t.Errorf("expected %q, but got %q\n", tt.expected, actual)
+		}
+	}
+}
+
+func TestLaTeX(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected string
+	}{
+		{"# $5 & 10%\n#### Sub_x", "\\section{\\$5 \\& 10\\%}\\label{5--10}\n\n\\paragraph{Sub\\_x}\\label{sub_x}\n\n"},
+		{"*em* **strong** ~~del~~ `a_b` [link](https://example.org/#a) ![image](image.png)",
+			"\\emph{em} \\textbf{strong} \\sout{del} \\texttt{a\\_b} \\href{https://example.org/\\#a}{link} \\includegraphics{image.png}\n\n"},
+		{"![a](my_image%20a&b.png#top) ![b](100%25.png) ![c](https://example.org/a%20b.png?x=1#c)",
+			"\\includegraphics{\\detokenize{my_image a&b.png}} \\href{100\\%25.png}{b} \\href{https://example.org/a\\%20b.png?x=1\\#c}{c}\n\n"},
+		{"- list1\n  - list1-1\n> quote ~ ^ \\", "\\begin{itemize}\n\\item list1\n\\begin{itemize}\n\\item list1-1\n\\end{itemize}\n\\end{itemize}\n\n\\begin{quote}\nquote \\textasciitilde{} \\textasciicircum{} \\textbackslash{}\n\\end{quote}\n\n"},
+		{"```python\nprint(1)\n```\n```go\n{}\n```", "\\begin{lstlisting}[language=Python]\nprint(1)\n\\end{lstlisting}\n\n\\begin{verbatim}\n{}\n\\end{verbatim}\n\n"},
+		{"note[^1] again[^1]\n\n[^1]: foot", "note\\footnote[1]{foot} again\\footnotemark[1]\n\n"},
+		{"note[^1]\n\n[^1]: see `a{b}`\n\n    ```c\n    if (a) {\n      b % 2;\n    ```", "note\\footnote[1]{see \\texttt{a\\{b\\}}\n\n\\texttt{if~(a)~\\{}\\\\\n\\texttt{~~b~\\%~2;}}\n\n"},
+		{"3. three\n   1. one\n4. four", "\\begin{enumerate}\n\\setcounter{enumi}{2}\n\\item three\n\\begin{enumerate}\n\\item one\n\\end{enumerate}\n\\item four\n\\end{enumerate}\n\n"},
+		{"| a | b_c |\n| :-: | --: |\n| x < y | 1 |", "\\begin{tabular}{cr}\n\\hline\n\\textbf{a} & \\textbf{b\\_c} \\\\\n\\hline\nx \\textless{} y & 1 \\\\\n\\hline\n\\end{tabular}\n\n"},
+	}
+
+	for _, tt := range testcases {
+		actual, err := LaTeX([]byte(tt.input))
+		if err != nil {
+			t.Errorf("unexpected err: %v\n", err)
+		}
+		if string(actual) != tt.expected {
+			t.Errorf("expected %q, but got %q\n", tt.expected, actual)
+		}
+	}
+}
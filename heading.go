@@ -0,0 +1,107 @@
+package gom2h
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// heading ids
+
+var (
+	headingIDExp = regexp.MustCompile(`[ \t]*\{#([^}\s]+)\}[ \t]*$`)
+	tagExp       = regexp.MustCompile(`<[^>]*>`)
+)
+
+// headingIDs sets a unique id to each heading without one.
+// Headings without an explicit {#id} get a GitHub compatible slug, which
+// does not repeat any explicit id of the document.
+func (c *converter) headingIDs(doc ast.Node) {
+	var slugged []*ast.Heading
+	ast.Walk(doc, func(n ast.Node, entering bool) ast.WalkStatus {
+		h, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue
+		}
+
+		if h.ID != "" {
+			c.ids[h.ID] = true
+		} else {
+			slugged = append(slugged, h)
+		}
+		return ast.WalkSkipChildren
+	})
+
+	for _, h := range slugged {
+		h.ID = c.uniqueID(slug(plainText(h)))
+	}
+}
+
+// headings returns the headings of doc outside of footnotes
+func headings(doc ast.Node) []Heading {
+	var ret []Heading
+	ast.Walk(doc, func(n ast.Node, entering bool) ast.WalkStatus {
+		switch n := n.(type) {
+		case *ast.Footnotes:
+			return ast.WalkSkipChildren
+		case *ast.Heading:
+			if entering {
+				ret = append(ret, Heading{Level: n.Level, Text: plainText(n), ID: n.ID})
+			}
+			return ast.WalkSkipChildren
+		}
+		return ast.WalkContinue
+	})
+	return ret
+}
+
+func (c *converter) uniqueID(slug string) string {
+	id := slug
+	for i := 1; c.ids[id]; i++ {
+		id = fmt.Sprintf("%s-%d", slug, i)
+	}
+	c.ids[id] = true
+	return id
+}
+
+// plainText returns the text content of the inline nodes of n
+func plainText(n ast.Node) string {
+	var b strings.Builder
+	ast.Walk(n, func(n ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			b.WriteString(stripTags(string(n.Value)))
+		case *ast.CodeSpan:
+			b.WriteString(html.UnescapeString(string(n.Code)))
+		case *ast.FootnoteRef:
+			b.WriteString(strconv.Itoa(n.Num))
+		}
+		return ast.WalkContinue
+	})
+	return b.String()
+}
+
+// slug lowercases s, drops punctuation and replaces spaces with hyphens
+func slug(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsNumber(r), unicode.IsMark(r), r == '-', r == '_':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune('-')
+		}
+	}
+	if b.Len() == 0 {
+		return "section"
+	}
+	return b.String()
+}
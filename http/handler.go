@@ -0,0 +1,358 @@
+// Package http serves markdown files as html pages.
+//
+//	docs, _ := fs.Sub(embedded, "docs")
+//	mux.Handle("/docs/", http.StripPrefix("/docs", gom2hhttp.NewHandler(docs)))
+//
+// Markdown files are served as pages of the template, also as name.html
+// for name.md and name.markdown, and as they are to clients which prefer
+// text/markdown. Directories are served with their index.md or README.md,
+// or a page listing their entries. Hidden files and directories, like .git
+// or .env, are not served. Responses have an ETag and Last-Modified and conditional
+// requests are answered with 304 Not Modified. Conversion errors are shown
+// as pages of the template.
+package http
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matsuyoshi30/gom2h"
+)
+
+// Page is the data of the template
+type Page struct {
+	Title   string
+	Meta    map[string]interface{}
+	Content template.HTML
+	TOC     template.HTML
+	// Path is the cleaned path of the request
+	Path string
+	// Entries are the entries of a directory listing
+	Entries []Entry
+}
+
+// Entry is an entry of a directory listing
+type Entry struct {
+	Name  string
+	IsDir bool
+}
+
+// DefaultTemplate is the template of the pages without WithTemplate
+var DefaultTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>{{ .Title }}</title>
+  </head>
+  <body>
+    <article class="markdown-body">
+      {{ .Content }}
+    </article>
+  </body>
+</html>
+`))
+
+// Handler serves the markdown files of a file system
+type Handler struct {
+	fsys    fs.FS
+	tmpl    *template.Template
+	opts    []gom2h.Option
+	indexes []string
+	render  func(name string, b []byte) ([]byte, error)
+}
+
+// Option configures a Handler
+type Option func(h *Handler)
+
+// WithTemplate renders the pages with t, executed with a Page
+func WithTemplate(t *template.Template) Option {
+	return func(h *Handler) {
+		h.tmpl = t
+	}
+}
+
+// WithOptions converts the markdown files with opts
+func WithOptions(opts ...gom2h.Option) Option {
+	return func(h *Handler) {
+		h.opts = append(h.opts, opts...)
+	}
+}
+
+// WithRender renders the markdown files with render instead of the
+// template, called with the name of the file in the file system and its
+// content. The template still renders the directory listings and errors.
+func WithRender(render func(name string, b []byte) ([]byte, error)) Option {
+	return func(h *Handler) {
+		h.render = render
+	}
+}
+
+// WithIndexes sets the files served for a directory, index.md and
+// README.md by default
+func WithIndexes(names ...string) Option {
+	return func(h *Handler) {
+		h.indexes = names
+	}
+}
+
+// NewHandler returns a Handler for the markdown files of fsys
+func NewHandler(fsys fs.FS, opts ...Option) *Handler {
+	h := &Handler{fsys: fsys, tmpl: DefaultTemplate, indexes: []string{"index.md", "README.md"}}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	p := path.Clean("/" + r.URL.Path)
+	if hidden(p) {
+		http.NotFound(w, r)
+		return
+	}
+	name := strings.TrimPrefix(p, "/")
+	if name == "" {
+		name = "."
+	}
+
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil {
+		// name.html is served from name.md or name.markdown
+		if path.Ext(name) == ".html" {
+			for _, ext := range []string{".md", ".markdown"} {
+				md := strings.TrimSuffix(name, ".html") + ext
+				if info, err := fs.Stat(h.fsys, md); err == nil && !info.IsDir() {
+					h.serveMarkdown(w, r, md, p, info)
+					return
+				}
+			}
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	if info.IsDir() {
+		// relative links of the directory page need the slash
+		if r.URL.Path != "" && !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, path.Base(p)+"/", http.StatusMovedPermanently)
+			return
+		}
+		for _, index := range h.indexes {
+			file := path.Join(name, index)
+			if info, err := fs.Stat(h.fsys, file); err == nil && !info.IsDir() {
+				h.serveMarkdown(w, r, file, p, info)
+				return
+			}
+		}
+		h.serveListing(w, r, name, p, info)
+		return
+	}
+
+	if isMarkdown(name) {
+		h.serveMarkdown(w, r, name, p, info)
+		return
+	}
+	b, err := fs.ReadFile(h.fsys, name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	serve(w, r, name, info.ModTime(), b)
+}
+
+// serveMarkdown serves the markdown file name as a page, or as it is to
+// clients which accept text/markdown
+func (h *Handler) serveMarkdown(w http.ResponseWriter, r *http.Request, name, p string, info fs.FileInfo) {
+	b, err := fs.ReadFile(h.fsys, name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if acceptsMarkdown(r) {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		serve(w, r, name, info.ModTime(), b)
+		return
+	}
+
+	var page []byte
+	if h.render != nil {
+		page, err = h.render(name, b)
+	} else {
+		page, err = h.convert(name, p, b)
+	}
+	if perr, ok := err.(*gom2h.ParseError); ok {
+		h.serveError(w, p, fmt.Errorf("%s:%v", name, perr))
+		return
+	} else if err != nil {
+		h.serveError(w, p, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	serve(w, r, p, info.ModTime(), page)
+}
+
+// convert returns the page of the template for the markdown file name
+func (h *Handler) convert(name, p string, b []byte) ([]byte, error) {
+	res, err := gom2h.Convert(b, h.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	title := strings.TrimSuffix(path.Base(name), path.Ext(name))
+	if v, ok := res.Meta["title"]; ok && v != nil {
+		title = fmt.Sprint(v)
+	} else if len(res.Headings) > 0 {
+		title = res.Headings[0].Text
+	}
+	var buf bytes.Buffer
+	err = h.tmpl.Execute(&buf, Page{
+		Title:   title,
+		Meta:    res.Meta,
+		Content: template.HTML(res.HTML),
+		TOC:     template.HTML(gom2h.TOC(res.Headings)),
+		Path:    p,
+	})
+	return buf.Bytes(), err
+}
+
+// serveError serves err as a page of the template, like the page at the
+// path p would be
+func (h *Handler) serveError(w http.ResponseWriter, p string, err error) {
+	var buf bytes.Buffer
+	if h.tmpl.Execute(&buf, Page{
+		Title:   p,
+		Content: template.HTML("<pre>" + html.EscapeString(err.Error()) + "</pre>"),
+		Path:    p,
+	}) != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write(buf.Bytes())
+}
+
+// serveListing serves a page listing the entries of the directory name
+func (h *Handler) serveListing(w http.ResponseWriter, r *http.Request, name, p string, info fs.FileInfo) {
+	dirents, err := fs.ReadDir(h.fsys, name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	entries := make([]Entry, 0, len(dirents))
+	var b strings.Builder
+	fmt.Fprintf(&b, "<h1>%s</h1>\n<ul>\n", html.EscapeString(p))
+	for _, d := range dirents {
+		if strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		entries = append(entries, Entry{Name: d.Name(), IsDir: d.IsDir()})
+		href := d.Name()
+		if d.IsDir() {
+			href += "/"
+		}
+		// a name like a:b or a#b is not a URL
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString((&url.URL{Path: href}).String()), html.EscapeString(href))
+	}
+	b.WriteString("</ul>")
+
+	h.servePage(w, r, info.ModTime(), Page{
+		Title:   p,
+		Content: template.HTML(b.String()),
+		Path:    p,
+		Entries: entries,
+	})
+}
+
+func (h *Handler) servePage(w http.ResponseWriter, r *http.Request, modTime time.Time, page Page) {
+	var buf bytes.Buffer
+	if err := h.tmpl.Execute(&buf, page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	serve(w, r, page.Path, modTime, buf.Bytes())
+}
+
+// serve writes b with an ETag of its content and answers conditional
+// requests
+func serve(w http.ResponseWriter, r *http.Request, name string, modTime time.Time, b []byte) {
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha1.Sum(b)))
+	http.ServeContent(w, r, name, modTime, bytes.NewReader(b))
+}
+
+// acceptsMarkdown reports whether r prefers text/markdown to text/html,
+// by the q-values of the Accept header and then by their order
+func acceptsMarkdown(r *http.Request) bool {
+	md, htm := acceptRange{q: -1}, acceptRange{q: -1}
+	var wildcard acceptRange
+	for i, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		params := strings.Split(part, ";")
+		a := acceptRange{q: 1, index: i}
+		for _, param := range params[1:] {
+			if kv := strings.SplitN(strings.TrimSpace(param), "=", 2); len(kv) == 2 && kv[0] == "q" {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					a.q = q
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(params[0])) {
+		case "text/markdown", "text/x-markdown":
+			if a.q > md.q {
+				md = a
+			}
+		case "text/html":
+			htm = a
+		case "text/*", "*/*":
+			if a.q > wildcard.q {
+				wildcard = a
+			}
+		}
+	}
+	// text/html is accepted by the wildcards unless it is listed
+	if htm.q < 0 {
+		htm = wildcard
+	}
+	return md.q > 0 && (md.q > htm.q || md.q == htm.q && md.index < htm.index)
+}
+
+// acceptRange is a media range of the Accept header
+type acceptRange struct {
+	q     float64
+	index int
+}
+
+// hidden reports whether an element of the path p starts with a dot
+func hidden(p string) bool {
+	for _, elem := range strings.Split(p, "/") {
+		if strings.HasPrefix(elem, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func isMarkdown(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".md" || ext == ".markdown"
+}
@@ -0,0 +1,154 @@
+package http
+
+import (
+	"errors"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/matsuyoshi30/gom2h"
+)
+
+func TestHandler(t *testing.T) {
+	modTime := time.Date(2021, 7, 10, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"index.md":         {Data: []byte("# Home\n"), ModTime: modTime},
+		"guide/intro.md":   {Data: []byte("---\ntitle: Introduction\n---\n# Intro\n"), ModTime: modTime},
+		"guide/logo.png":   {Data: []byte("\x89PNG"), ModTime: modTime},
+		"guide/.hidden.md": {Data: []byte("# Hidden\n"), ModTime: modTime},
+		".git/config":      {Data: []byte("[core]\n"), ModTime: modTime},
+		".env":             {Data: []byte("TOKEN=secret\n"), ModTime: modTime},
+		"api/README.md":    {Data: []byte("# API\n"), ModTime: modTime},
+		"notes.markdown":   {Data: []byte("# Notes\n"), ModTime: modTime},
+	}
+	tmpl := template.Must(template.New("page").Parse(`<title>{{ .Title }}</title>{{ .Content }}`))
+	h := NewHandler(fsys, WithTemplate(tmpl))
+
+	testcases := []struct {
+		path        string
+		accept      string
+		status      int
+		contentType string
+		expected    string
+	}{
+		{"/", "", http.StatusOK, "text/html; charset=utf-8", `<title>Home</title><h1 id="home">Home</h1>`},
+		{"/guide/intro.md", "text/html", http.StatusOK, "text/html; charset=utf-8", `<title>Introduction</title><h1 id="intro">Intro</h1>`},
+		{"/guide/intro.html", "", http.StatusOK, "text/html; charset=utf-8", `<h1 id="intro">Intro</h1>`},
+		{"/guide/intro.md", "text/markdown, text/html;q=0.9", http.StatusOK, "text/markdown; charset=utf-8", "---\ntitle: Introduction\n---\n# Intro\n"},
+		{"/guide/intro.md", "text/markdown;q=0.1, text/html", http.StatusOK, "text/html; charset=utf-8", `<h1 id="intro">Intro</h1>`},
+		{"/guide/intro.md", "text/html;q=0.5, text/markdown", http.StatusOK, "text/markdown; charset=utf-8", "# Intro\n"},
+		{"/guide/intro.md", "text/markdown;q=0.5, */*", http.StatusOK, "text/html; charset=utf-8", `<h1 id="intro">Intro</h1>`},
+		{"/guide/intro.md", "text/markdown;q=0", http.StatusOK, "text/html; charset=utf-8", `<h1 id="intro">Intro</h1>`},
+		{"/notes.html", "", http.StatusOK, "text/html; charset=utf-8", `<h1 id="notes">Notes</h1>`},
+		{"/guide/", "", http.StatusOK, "text/html; charset=utf-8", "<ul>\n<li><a href=\"intro.md\">intro.md</a></li>\n<li><a href=\"logo.png\">logo.png</a></li>\n</ul>"},
+		{"/guide", "", http.StatusMovedPermanently, "", ""},
+		{"/api/", "", http.StatusOK, "text/html; charset=utf-8", `<h1 id="api">API</h1>`},
+		{"/guide/logo.png", "", http.StatusOK, "image/png", "\x89PNG"},
+		{"/missing.md", "", http.StatusNotFound, "", ""},
+		{"/.git/config", "", http.StatusNotFound, "", ""},
+		{"/.git/", "", http.StatusNotFound, "", ""},
+		{"/.env", "", http.StatusNotFound, "", ""},
+		{"/guide/.hidden.md", "", http.StatusNotFound, "", ""},
+		{"/guide/.hidden.html", "", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range testcases {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, but got %d\n", tt.path, tt.status, w.Code)
+		}
+		if tt.contentType != "" && w.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("%s: expected %s, but got %s\n", tt.path, tt.contentType, w.Header().Get("Content-Type"))
+		}
+		if body, _ := ioutil.ReadAll(w.Body); !strings.Contains(string(body), tt.expected) {
+			t.Errorf("%s: expected %q, but got %q\n", tt.path, tt.expected, body)
+		}
+	}
+}
+
+func TestHandlerCaching(t *testing.T) {
+	modTime := time.Date(2021, 7, 10, 0, 0, 0, 0, time.UTC)
+	h := NewHandler(fstest.MapFS{"index.md": {Data: []byte("# Home\n"), ModTime: modTime}})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected an ETag\n")
+	}
+	if lm := w.Header().Get("Last-Modified"); lm != modTime.Format(http.TimeFormat) {
+		t.Errorf("expected Last-Modified %s, but got %s\n", modTime.Format(http.TimeFormat), lm)
+	}
+
+	for header, value := range map[string]string{
+		"If-None-Match":     etag,
+		"If-Modified-Since": modTime.Format(http.TimeFormat),
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(header, value)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("%s: expected status %d, but got %d\n", header, http.StatusNotModified, w.Code)
+		}
+	}
+
+	// the raw markdown has its own ETag
+	r := httptest.NewRequest(http.MethodGet, "/index.md", nil)
+	r.Header.Set("Accept", "text/markdown")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Header().Get("ETag") == etag {
+		t.Errorf("expected another ETag for markdown\n")
+	}
+}
+
+func TestHandlerRender(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.md":  {Data: []byte("# Home\n")},
+		"broken.md": {Data: []byte("[^1]\n")},
+		"error.md":  {Data: []byte("# Error\n")},
+	}
+	tmpl := template.Must(template.New("page").Parse(`<title>{{ .Title }}</title>{{ .Content }}`))
+	h := NewHandler(fsys, WithTemplate(tmpl), WithRender(func(name string, b []byte) ([]byte, error) {
+		if name == "error.md" {
+			return nil, errors.New("no <layout>")
+		}
+		res, err := gom2h.Convert(b, gom2h.WithStrict())
+		if err != nil {
+			return nil, err
+		}
+		return []byte("<main>" + string(res.HTML) + "</main>"), nil
+	}))
+
+	testcases := []struct {
+		path     string
+		status   int
+		expected string
+	}{
+		{"/", http.StatusOK, `<main><h1 id="home">Home</h1></main>`},
+		{"/broken.md", http.StatusInternalServerError, "<title>/broken.md</title><pre>broken.md:1:1: undefined footnote"},
+		{"/error.md", http.StatusInternalServerError, "<pre>no &lt;layout&gt;</pre>"},
+	}
+	for _, tt := range testcases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, but got %d\n", tt.path, tt.status, w.Code)
+		}
+		if body, _ := ioutil.ReadAll(w.Body); !strings.Contains(string(body), tt.expected) {
+			t.Errorf("%s: expected %q, but got %q\n", tt.path, tt.expected, body)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package gom2h
+
+import (
+	"bytes"
+
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// inline
+//
+// A line is split into segments of text, delimiter runs and inline nodes
+// such as code spans and links. Delimiter runs are then matched to
+// emphasis, see emphasis.go.
+
+// parseInline parses text into inline nodes appended to parent
+func (c *converter) parseInline(parent ast.Node, text []byte) {
+	segs := c.segments(text)
+	emphasis(segs)
+
+	stack := []ast.Node{parent}
+	for _, s := range segs {
+		top := stack[len(stack)-1]
+		switch {
+		case s.node != nil:
+			ast.AppendChild(top, s.node)
+		case s.d == nil:
+			c.appendText(top, s.text)
+		default:
+			// closing delimiters, remaining delimiters, opening delimiters
+			src := s.d.src
+			for _, m := range s.d.close {
+				extend(top, c.src.span(src[:m.n]))
+				src = src[m.n:]
+				stack = stack[:len(stack)-1]
+				top = stack[len(stack)-1]
+			}
+			c.appendText(top, src[:s.d.n])
+			src = src[s.d.n:]
+			for _, m := range s.d.open {
+				n := newEmphasis(m.kind)
+				n.SetSpan(c.src.span(src[:m.n]))
+				src = src[m.n:]
+				ast.AppendChild(top, n)
+				stack = append(stack, n)
+				top = n
+			}
+		}
+	}
+}
+
+func (c *converter) segments(line []byte) []segment {
+	segs := make([]segment, 0)
+
+	start := 0
+	flush := func(end int) {
+		if start < end {
+			segs = append(segs, segment{text: line[start:end]})
+		}
+	}
+	for i := 0; i < len(line); {
+		if n, size := c.inlineAt(line[i:]); size > 0 {
+			if n.Span().IsZero() {
+				n.SetSpan(c.src.span(line[i : i+size]))
+			}
+			flush(i)
+			segs = append(segs, segment{node: n})
+			i += size
+			start = i
+			continue
+		}
+
+		if !isDelim(line[i]) {
+			i++
+			continue
+		}
+		flush(i)
+		j := i
+		for j < len(line) && line[j] == line[i] {
+			j++
+		}
+		segs = append(segs, segment{d: newDelim(line, i, j)})
+		i = j
+		start = j
+	}
+	flush(len(line))
+
+	return segs
+}
+
+// inlineAt parses the inline node at the start of line and returns it
+// with its length in bytes, or 0 if there is none
+func (c *converter) inlineAt(line []byte) (ast.Node, int) {
+	if n, size := c.parseCustomInline(line); size > 0 {
+		return n, size
+	}
+
+	switch line[0] {
+	case '`':
+		return codeSpan(line)
+
+	case '!':
+		// ![image](/path/to/image)
+		if len(line) > 1 && line[1] == '[' {
+			if alt, dest, size := linkAt(line[1:]); size > 0 {
+				return &ast.Image{Dest: dest, Alt: alt}, size + 1
+			}
+			// ![image][label]
+			if alt, label, size := refLinkAt(line[1:]); size > 0 {
+				if ref := c.refs.lookup(label); ref != nil {
+					return &ast.Image{Dest: ref.dest, Alt: alt, Ref: string(label)}, size + 1
+				}
+			}
+		}
+
+	case '[':
+		// This is footnote[^1].
+		if m := footnoteRefExp.FindSubmatch(line); m != nil && c.fns != nil {
+			if ref := c.fns.ref(m[1]); ref != nil {
+				return ref, len(m[0])
+			}
+			if c.strict && c.err == nil {
+				c.err = c.src.errorAt(line, "undefined footnote %s", m[1])
+			}
+		}
+		// This is [link](https://example.org/)
+		if text, dest, size := linkAt(line); size > 0 {
+			l := &ast.Link{Dest: dest}
+			c.parseInline(l, text)
+			return l, size
+		}
+		// This is [link][label]
+		if text, label, size := refLinkAt(line); size > 0 {
+			if ref := c.refs.lookup(label); ref != nil {
+				l := &ast.Link{Dest: ref.dest, Ref: string(label)}
+				c.parseInline(l, text)
+				return l, size
+			}
+		}
+	}
+
+	return nil, 0
+}
+
+// codeSpan parses a code span opened by the backtick run at the start of
+// line. A run without a closing run of the same length is literal text.
+func codeSpan(line []byte) (ast.Node, int) {
+	n := 0
+	for n < len(line) && line[n] == '`' {
+		n++
+	}
+	for i := n; i < len(line); {
+		if line[i] != '`' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(line) && line[j] == '`' {
+			j++
+		}
+		if j-i == n {
+			return &ast.CodeSpan{Code: line[n:i]}, j
+		}
+		i = j
+	}
+	return &ast.Text{Value: append([]byte(nil), line[:n]...)}, n
+}
+
+// linkAt parses [text](dest) at the start of line and returns the text,
+// the destination and the length in bytes, or 0 if there is no link
+func linkAt(line []byte) ([]byte, []byte, int) {
+	end := matching(line, '[', ']')
+	if end < 0 || end+1 == len(line) || line[end+1] != '(' {
+		return nil, nil, 0
+	}
+	paren := matching(line[end+1:], '(', ')')
+	if paren < 0 {
+		return nil, nil, 0
+	}
+	paren += end + 1
+
+	return line[1:end], bytes.TrimSpace(line[end+2 : paren]), paren + 1
+}
+
+// matching returns the index of the bracket closing line[0], or -1
+func matching(line []byte, open, close byte) int {
+	depth := 0
+	for i, b := range line {
+		switch b {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// appendText adds text to parent, merging it into a preceding text node
+func (c *converter) appendText(parent ast.Node, text []byte) {
+	if len(text) == 0 {
+		return
+	}
+	if t, ok := parent.LastChild().(*ast.Text); ok {
+		t.Value = append(t.Value, text...)
+		extend(t, c.src.span(text))
+		return
+	}
+	t := &ast.Text{Value: append([]byte(nil), text...)}
+	t.SetSpan(c.src.span(text))
+	ast.AppendChild(parent, t)
+}
+
+func newEmphasis(k ast.Kind) ast.Node {
+	switch k {
+	case ast.KindStrong:
+		return &ast.Strong{}
+	case ast.KindStrikethrough:
+		return &ast.Strikethrough{}
+	}
+	return &ast.Emphasis{}
+}
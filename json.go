@@ -0,0 +1,123 @@
+package gom2h
+
+import (
+	"encoding/json"
+	"html"
+
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// json
+//
+// JSON writes the document tree as an object with the schema version and
+// the document node:
+//
+//	{"version": 1, "document": node}
+//
+// Each node has its type, the kind name like "Heading" or "Text", and
+// optionally attrs, span and children:
+//
+//	{
+//	  "type": "Heading",
+//	  "attrs": {"id": "header1", "level": 1},
+//	  "span": {"start": {"offset": 0, "line": 1, "col": 1}, "end": {...}},
+//	  "children": [node, ...]
+//	}
+//
+// The attrs by type are
+//
+//	Document     meta (front matter)
+//	Heading      level, id
+//	Blockquote   level
+//	List         ordered, start (ordered lists only)
+//	CodeBlock    lang, code
+//	HTMLBlock    raw
+//	Footnote     num, refs
+//	TableRow     header
+//	TableCell    align
+//	Text         value
+//	CodeSpan     code
+//	Link         dest, ref
+//	Image        dest, alt, ref
+//	FootnoteRef  num, ref
+//
+// Nodes without source like the footnotes section have no span.
+// Custom nodes have the name of their kind as type and no attrs.
+
+// JSONVersion is the version of the JSON schema. It is incremented when
+// types or attrs are changed or removed, not when they are added.
+const JSONVersion = 1
+
+type jsonDocument struct {
+	Version  int       `json:"version"`
+	Document *jsonNode `json:"document"`
+}
+
+type jsonNode struct {
+	Type     string                 `json:"type"`
+	Attrs    map[string]interface{} `json:"attrs,omitempty"`
+	Span     *ast.Span              `json:"span,omitempty"`
+	Children []*jsonNode            `json:"children,omitempty"`
+}
+
+// JSON converts markdown to the JSON of its document tree
+func JSON(input []byte, opts ...Option) ([]byte, error) {
+	c := newConverter(opts)
+	doc, err := c.convert(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(jsonDocument{Version: JSONVersion, Document: newJSONNode(doc)}, "", "  ")
+}
+
+func newJSONNode(n ast.Node) *jsonNode {
+	j := &jsonNode{Type: n.Kind().String(), Attrs: jsonAttrs(n)}
+	if span := n.Span(); !span.IsZero() {
+		j.Span = &span
+	}
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		j.Children = append(j.Children, newJSONNode(child))
+	}
+	return j
+}
+
+func jsonAttrs(n ast.Node) map[string]interface{} {
+	switch n := n.(type) {
+	case *ast.Document:
+		if n.Meta != nil {
+			return map[string]interface{}{"meta": n.Meta}
+		}
+	case *ast.Heading:
+		return map[string]interface{}{"level": n.Level, "id": n.ID}
+	case *ast.Blockquote:
+		return map[string]interface{}{"level": n.Level}
+	case *ast.List:
+		if n.Ordered {
+			return map[string]interface{}{"ordered": true, "start": n.Start}
+		}
+	case *ast.CodeBlock:
+		return map[string]interface{}{"lang": string(n.Lang), "code": html.UnescapeString(string(n.Code))}
+	case *ast.HTMLBlock:
+		return map[string]interface{}{"raw": string(n.Raw)}
+	case *ast.Footnote:
+		return map[string]interface{}{"num": n.Num, "refs": n.Refs}
+	case *ast.TableRow:
+		return map[string]interface{}{"header": n.Header}
+	case *ast.TableCell:
+		if n.Align != "" {
+			return map[string]interface{}{"align": n.Align}
+		}
+	case *ast.Text:
+		return map[string]interface{}{"value": string(n.Value)}
+	case *ast.CodeSpan:
+		return map[string]interface{}{"code": string(n.Code)}
+	case *ast.Link:
+		return map[string]interface{}{"dest": string(n.Dest), "ref": n.Ref}
+	case *ast.Image:
+		return map[string]interface{}{"dest": string(n.Dest), "alt": string(n.Alt), "ref": n.Ref}
+	case *ast.FootnoteRef:
+		return map[string]interface{}{"num": n.Num, "ref": n.Ref}
+	}
+	return nil
+}
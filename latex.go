@@ -0,0 +1,256 @@
+package gom2h
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// latex
+//
+// LaTeX writes the body of a LaTeX document, to be put into a document
+// which loads the graphicx, listings, ulem (normalem) and hyperref packages.
+// Footnotes are written where they are referenced.
+
+var (
+	latexEscaper = strings.NewReplacer(
+		`\`, `\textbackslash{}`,
+		"{", `\{`, "}", `\}`,
+		"$", `\$`, "&", `\&`, "#", `\#`, "%", `\%`, "_", `\_`,
+		"~", `\textasciitilde{}`, "^", `\textasciicircum{}`,
+		"|", `\textbar{}`, "<", `\textless{}`, ">", `\textgreater{}`,
+	)
+	latexURLEscaper = strings.NewReplacer(`\`, `\\`, "#", `\#`, "%", `\%`)
+)
+
+// languages known to the listings package
+var latexLanguages = map[string]string{
+	"bash":    "bash",
+	"sh":      "bash",
+	"shell":   "bash",
+	"c":       "C",
+	"cpp":     "C++",
+	"c++":     "C++",
+	"haskell": "Haskell",
+	"html":    "HTML",
+	"java":    "Java",
+	"lisp":    "Lisp",
+	"make":    "make",
+	"perl":    "Perl",
+	"php":     "PHP",
+	"python":  "Python",
+	"ruby":    "Ruby",
+	"sql":     "SQL",
+	"tex":     "TeX",
+	"xml":     "XML",
+}
+
+var latexSections = []string{"section", "subsection", "subsubsection", "paragraph", "subparagraph", "subparagraph"}
+
+// the counters of nested enumerate environments
+var latexCounters = []string{"enumi", "enumii", "enumiii", "enumiv"}
+
+// EscapeLaTeX escapes the characters of s which are special in LaTeX
+func EscapeLaTeX(s string) string {
+	return latexEscaper.Replace(s)
+}
+
+// LaTeX converts markdown to the body of a LaTeX document
+func LaTeX(input []byte, opts ...Option) ([]byte, error) {
+	c := newConverter(opts)
+	doc, err := c.convert(input)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &latexWriter{c: c, footnotes: make(map[int]ast.Node)}
+	for _, n := range ast.Children(doc) {
+		if n.Kind() == ast.KindFootnotes {
+			for _, fn := range ast.Children(n) {
+				w.footnotes[fn.(*ast.Footnote).Num] = fn
+			}
+		}
+	}
+
+	var b strings.Builder
+	w.blocks(&b, doc)
+	return []byte(b.String()), nil
+}
+
+type latexWriter struct {
+	c          *converter
+	footnotes  map[int]ast.Node
+	enum       int  // depth of enumerate environments
+	inFootnote bool // verbatim is not allowed in footnotes
+}
+
+func (w *latexWriter) blocks(b *strings.Builder, parent ast.Node) {
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		switch n := n.(type) {
+		case *ast.Heading:
+			fmt.Fprintf(b, "\\%s{%s}\\label{%s}\n\n", latexSections[n.Level-1], w.inline(n), latexURLEscaper.Replace(n.ID))
+		case *ast.Blockquote:
+			fmt.Fprintf(b, "\\begin{quote}\n%s\n\\end{quote}\n\n", w.inline(n))
+		case *ast.List:
+			w.list(b, n)
+			b.WriteString("\n")
+		case *ast.Table:
+			w.table(b, n)
+		case *ast.CodeBlock:
+			code := html.UnescapeString(string(n.Code))
+			if w.inFootnote {
+				fmt.Fprintf(b, "%s\n\n", latexCodeLines(code))
+			} else if lang, ok := latexLanguages[strings.ToLower(string(n.Lang))]; ok {
+				fmt.Fprintf(b, "\\begin{lstlisting}[language=%s]\n%s\\end{lstlisting}\n\n", lang, code)
+			} else {
+				fmt.Fprintf(b, "\\begin{verbatim}\n%s\\end{verbatim}\n\n", code)
+			}
+		case *ast.HTMLBlock:
+			fmt.Fprintf(b, "%s\n\n", EscapeLaTeX(stripTags(string(n.Raw))))
+		case *ast.TOC:
+			b.WriteString("\\tableofcontents\n\n")
+		case *ast.Footnotes:
+			// written at the references
+		default:
+			if n.FirstChild() != nil && !n.FirstChild().Kind().IsBlock() {
+				fmt.Fprintf(b, "%s\n\n", w.inline(n))
+			} else {
+				w.blocks(b, n)
+			}
+		}
+	}
+}
+
+// list writes an itemize environment, or an enumerate environment
+// starting at the number of an ordered list
+func (w *latexWriter) list(b *strings.Builder, l ast.Node) {
+	env := "itemize"
+	list, ok := l.(*ast.List)
+	if ok && list.Ordered {
+		env = "enumerate"
+		w.enum++
+		defer func() { w.enum-- }()
+	}
+	fmt.Fprintf(b, "\\begin{%s}\n", env)
+	if env == "enumerate" && list.Start != 1 && w.enum <= len(latexCounters) {
+		fmt.Fprintf(b, "\\setcounter{%s}{%d}\n", latexCounters[w.enum-1], list.Start-1)
+	}
+	for n := l.FirstChild(); n != nil; n = n.NextSibling() {
+		if n.Kind() == ast.KindList {
+			w.list(b, n)
+			continue
+		}
+		fmt.Fprintf(b, "\\item %s\n", w.inline(n))
+	}
+	fmt.Fprintf(b, "\\end{%s}\n", env)
+}
+
+// table writes a tabular environment with a rule below the bold header
+func (w *latexWriter) table(b *strings.Builder, t *ast.Table) {
+	var spec strings.Builder
+	for _, a := range tableAlign(t) {
+		switch a {
+		case "center":
+			spec.WriteString("c")
+		case "right":
+			spec.WriteString("r")
+		default:
+			spec.WriteString("l")
+		}
+	}
+
+	fmt.Fprintf(b, "\\begin{tabular}{%s}\n\\hline\n", spec.String())
+	for row := t.FirstChild(); row != nil; row = row.NextSibling() {
+		header := row.(*ast.TableRow).Header
+		cells := make([]string, 0)
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			text := w.inline(cell)
+			if header && text != "" {
+				text = `\textbf{` + text + `}`
+			}
+			cells = append(cells, text)
+		}
+		fmt.Fprintf(b, "%s \\\\\n", strings.Join(cells, " & "))
+		if header {
+			b.WriteString("\\hline\n")
+		}
+	}
+	b.WriteString("\\hline\n\\end{tabular}\n\n")
+}
+
+// inline returns the LaTeX of the inline nodes of parent
+func (w *latexWriter) inline(parent ast.Node) string {
+	var b strings.Builder
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		switch n := n.(type) {
+		case *ast.Text:
+			b.WriteString(EscapeLaTeX(stripTags(string(n.Value))))
+		case *ast.Emphasis:
+			b.WriteString(`\emph{` + w.inline(n) + `}`)
+		case *ast.Strong:
+			b.WriteString(`\textbf{` + w.inline(n) + `}`)
+		case *ast.Strikethrough:
+			b.WriteString(`\sout{` + w.inline(n) + `}`)
+		case *ast.CodeSpan:
+			b.WriteString(`\texttt{` + EscapeLaTeX(string(n.Code)) + `}`)
+		case *ast.Link:
+			b.WriteString(`\href{` + latexURLEscaper.Replace(string(n.Dest)) + `}{` + w.inline(n) + `}`)
+		case *ast.Image:
+			b.WriteString(latexImage(n))
+		case *ast.FootnoteRef:
+			b.WriteString(w.footnote(n))
+		default:
+			b.WriteString(w.inline(n))
+		}
+	}
+	return b.String()
+}
+
+// latexImage includes the local image n. External images, and paths TeX
+// can not read even detokenized, are linked instead.
+func latexImage(n *ast.Image) string {
+	dest := string(n.Dest)
+	if !IsAbsURL(dest) {
+		// the destination is a URL, the file is its decoded path
+		p := dest
+		if i := strings.IndexAny(p, "?#"); i >= 0 {
+			p = p[:i]
+		}
+		if file, err := url.PathUnescape(p); err == nil && file != "" && !strings.ContainsAny(file, "%#\\{}") {
+			if EscapeLaTeX(file) != file {
+				file = `\detokenize{` + file + `}`
+			}
+			return `\includegraphics{` + file + `}`
+		}
+	}
+	return `\href{` + latexURLEscaper.Replace(dest) + `}{` + EscapeLaTeX(string(n.Alt)) + `}`
+}
+
+// latexCodeLines returns the escaped lines of code in \texttt, keeping the
+// spaces and line breaks
+func latexCodeLines(code string) string {
+	lines := strings.Split(strings.TrimSuffix(code, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = `\texttt{` + strings.Replace(EscapeLaTeX(line), " ", "~", -1) + `}`
+	}
+	return strings.Join(lines, "\\\\\n")
+}
+
+// footnote writes the footnote at its first reference and a mark at the
+// following ones. Code blocks in the footnote are written line by line
+// with \texttt, since verbatim can not be put into footnotes.
+func (w *latexWriter) footnote(ref *ast.FootnoteRef) string {
+	fn, ok := w.footnotes[ref.Num]
+	if !ok || ref.Ref > 1 {
+		return fmt.Sprintf(`\footnotemark[%d]`, ref.Num)
+	}
+
+	var b strings.Builder
+	w.inFootnote = true
+	w.blocks(&b, fn)
+	w.inFootnote = false
+	return fmt.Sprintf(`\footnote[%d]{%s}`, ref.Num, strings.TrimSpace(b.String()))
+}
@@ -0,0 +1,86 @@
+package gom2h
+
+import (
+	"regexp"
+	"strings"
+)
+
+// link reference definitions
+//
+// [text][label], [label][] and [label] link to the destination of a
+// definition line [label]: dest anywhere in the document.
+
+var linkDefExp = regexp.MustCompile(`^ {0,3}\[([^\]^][^\]]*)\]:[ \t]*(\S+)[ \t]*$`)
+
+type linkRef struct {
+	label string // as written in the definition
+	dest  []byte
+}
+
+type linkRefs struct {
+	defs  map[string]*linkRef
+	order []*linkRef // in source order
+}
+
+// collectLinkRefs removes link reference definitions from lines.
+// The first definition of a label is used.
+func collectLinkRefs(lines [][]byte) ([][]byte, *linkRefs) {
+	refs := &linkRefs{defs: make(map[string]*linkRef)}
+
+	ret := make([][]byte, 0, len(lines))
+	inFence := false
+	for _, line := range lines {
+		if codefenceExp.Match(line) {
+			inFence = !inFence
+		}
+		if !inFence && linkDefExp.Match(line) {
+			m := linkDefExp.FindSubmatch(line)
+			key := normalizeLabel(m[1])
+			if _, ok := refs.defs[key]; !ok {
+				ref := &linkRef{label: string(m[1]), dest: m[2]}
+				refs.defs[key] = ref
+				refs.order = append(refs.order, ref)
+			}
+			continue
+		}
+
+		ret = append(ret, line)
+	}
+
+	return ret, refs
+}
+
+// lookup returns the definition of label, or nil if there is none
+func (refs *linkRefs) lookup(label []byte) *linkRef {
+	if refs == nil {
+		return nil
+	}
+	return refs.defs[normalizeLabel(label)]
+}
+
+// normalizeLabel matches labels case-insensitively and ignoring the
+// amount of whitespace
+func normalizeLabel(label []byte) string {
+	return strings.ToLower(strings.Join(strings.Fields(string(label)), " "))
+}
+
+// refLinkAt parses [text][label], [text][] or [text] at the start of line
+// and returns the text, the label and the length in bytes, or 0 if there
+// is no reference link
+func refLinkAt(line []byte) ([]byte, []byte, int) {
+	end := matching(line, '[', ']')
+	if end < 0 {
+		return nil, nil, 0
+	}
+	text := line[1:end]
+	if end+1 < len(line) && line[end+1] == '[' {
+		if close := matching(line[end+1:], '[', ']'); close >= 0 {
+			close += end + 1
+			if label := line[end+2 : close]; len(label) > 0 {
+				return text, label, close + 1
+			}
+			return text, text, close + 1
+		}
+	}
+	return text, text, end + 1
+}
@@ -0,0 +1,195 @@
+package gom2h
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// man page
+//
+// Man writes a document as a roff man page. The .TH line is made of the
+// front matter keys title, section, date, source and manual. Headings of
+// level 1 are sections (.SH) and deeper headings subsections (.SS). Tables
+// are written for tbl, which man runs for pages starting with '\" t.
+
+var (
+	roffEscaper = strings.NewReplacer(`\`, `\e`, "-", `\-`)
+	roffLineEnd = strings.NewReplacer("\n.", "\n\\&.", "\n'", "\n\\&'")
+)
+
+// Man converts markdown to a roff man page
+func Man(input []byte, opts ...Option) ([]byte, error) {
+	c := newConverter(opts)
+	doc, err := c.convert(input)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := func(key, def string) string {
+		if v, ok := doc.Meta[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return def
+	}
+	title := meta("title", "")
+	if title == "" && len(c.headings) > 0 {
+		title = c.headings[0].Text
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, ".TH %s %s %s %s %s\n", roffQuote(strings.ToUpper(title)), roffQuote(meta("section", "1")),
+		roffQuote(meta("date", "")), roffQuote(meta("source", "")), roffQuote(meta("manual", "")))
+	c.roffBlocks(&b, doc)
+
+	page := b.String()
+	if strings.Contains(page, "\n.TS\n") {
+		page = "'\\\" t\n" + page
+	}
+	return []byte(page), nil
+}
+
+func (c *converter) roffBlocks(b *strings.Builder, parent ast.Node) {
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		switch n := n.(type) {
+		case *ast.Heading:
+			if n.Level == 1 {
+				fmt.Fprintf(b, ".SH %s\n", roffQuote(strings.ToUpper(plainText(n))))
+			} else {
+				fmt.Fprintf(b, ".SS %s\n", roffQuote(plainText(n)))
+			}
+		case *ast.Blockquote:
+			fmt.Fprintf(b, ".RS 4\n.PP\n%s\n.RE\n", c.roffInline(n))
+		case *ast.List:
+			c.roffList(b, n)
+		case *ast.Table:
+			c.roffTable(b, n)
+		case *ast.CodeBlock:
+			code := strings.TrimSuffix(html.UnescapeString(string(n.Code)), "\n")
+			fmt.Fprintf(b, ".PP\n.RS 4\n.nf\n%s\n.fi\n.RE\n", roffText(roffEscaper.Replace(code)))
+		case *ast.HTMLBlock:
+			fmt.Fprintf(b, ".PP\n%s\n", roffText(roffEscaper.Replace(stripTags(string(n.Raw)))))
+		case *ast.TOC:
+		case *ast.Footnotes:
+			b.WriteString(".SH NOTES\n")
+			c.roffBlocks(b, n)
+		case *ast.Footnote:
+			fmt.Fprintf(b, ".IP [%d] 4\n", n.Num)
+			for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+				if child != n.FirstChild() {
+					b.WriteString(".IP\n")
+				}
+				b.WriteString(c.roffInline(child) + "\n")
+			}
+		default:
+			if n.FirstChild() != nil && !n.FirstChild().Kind().IsBlock() {
+				fmt.Fprintf(b, ".PP\n%s\n", c.roffInline(n))
+			} else {
+				c.roffBlocks(b, n)
+			}
+		}
+	}
+}
+
+func (c *converter) roffList(b *strings.Builder, l ast.Node) {
+	marker := listMarker(l, "")
+	for n := l.FirstChild(); n != nil; n = n.NextSibling() {
+		if n.Kind() == ast.KindList {
+			b.WriteString(".RS 2\n")
+			c.roffList(b, n)
+			b.WriteString(".RE\n")
+			continue
+		}
+		if m := marker(); m != "" {
+			fmt.Fprintf(b, ".IP %s 4\n%s\n", strings.TrimSpace(m), c.roffInline(n))
+		} else {
+			fmt.Fprintf(b, ".IP \\(bu 2\n%s\n", c.roffInline(n))
+		}
+	}
+}
+
+// roffTable writes t as a boxed tbl table with a bold header row. Cells
+// are separated by tabs, the default of tbl.
+func (c *converter) roffTable(b *strings.Builder, t *ast.Table) {
+	b.WriteString(".PP\n.TS\nallbox;\n")
+	for i, a := range tableAlign(t) {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(roffAlign(a) + "b")
+	}
+	b.WriteString("\n")
+	for i, a := range tableAlign(t) {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(roffAlign(a))
+	}
+	b.WriteString(".\n")
+
+	for row := t.FirstChild(); row != nil; row = row.NextSibling() {
+		cells := make([]string, 0)
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			text := strings.NewReplacer("\n", " ", "\t", " ").Replace(c.roffInline(cell))
+			// a cell of _ or = alone draws a line
+			if text == "_" || text == "=" {
+				text = `\&` + text
+			}
+			cells = append(cells, text)
+		}
+		b.WriteString(strings.Join(cells, "\t") + "\n")
+	}
+	b.WriteString(".TE\n")
+}
+
+// roffAlign returns the tbl key letter of the column alignment a
+func roffAlign(a string) string {
+	switch a {
+	case "center":
+		return "c"
+	case "right":
+		return "r"
+	}
+	return "l"
+}
+
+// roffInline returns the escaped text of the inline nodes of parent
+func (c *converter) roffInline(parent ast.Node) string {
+	var b strings.Builder
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		switch n := n.(type) {
+		case *ast.Text:
+			b.WriteString(roffEscaper.Replace(stripTags(string(n.Value))))
+		case *ast.Emphasis:
+			b.WriteString(`\fI` + c.roffInline(n) + `\fP`)
+		case *ast.Strong:
+			b.WriteString(`\fB` + c.roffInline(n) + `\fP`)
+		case *ast.CodeSpan:
+			b.WriteString(`\fB` + roffEscaper.Replace(string(n.Code)) + `\fP`)
+		case *ast.Link:
+			text := c.roffInline(n)
+			b.WriteString(text)
+			if dest := roffEscaper.Replace(string(n.Dest)); dest != text {
+				b.WriteString(` \(la` + dest + `\(ra`)
+			}
+		case *ast.Image:
+			b.WriteString(roffEscaper.Replace(string(n.Alt)))
+		case *ast.FootnoteRef:
+			fmt.Fprintf(&b, "[%d]", n.Num)
+		default:
+			b.WriteString(c.roffInline(n))
+		}
+	}
+	return roffText(b.String())
+}
+
+// roffText keeps lines of text from being read as requests
+func roffText(s string) string {
+	return strings.TrimPrefix(roffLineEnd.Replace("\n"+s), "\n")
+}
+
+func roffQuote(s string) string {
+	return `"` + strings.Replace(roffEscaper.Replace(s), `"`, `\(dq`, -1) + `"`
+}
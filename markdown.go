@@ -0,0 +1,372 @@
+package gom2h
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// markdown formatter
+//
+// Format writes a parsed document back as markdown in one style: ATX
+// headings, `-` bullets and `1.` numbers indented by two spaces per level,
+// fenced code blocks, tables with aligned columns and a blank line between
+// blocks. Footnote and link reference definitions are collected at the
+// bottom in the order they are first used. Front matter and custom nodes
+// are written as they are in the source.
+
+// WithWrap wraps paragraphs written by Format and Terminal at width
+// columns. Format only wraps paragraphs with soft breaks, without them each
+// line is a paragraph which must stay on its line.
+func WithWrap(width int) Option {
+	return func(c *converter) {
+		c.wrap = width
+	}
+}
+
+// Format parses markdown and writes it back normalized
+func Format(input []byte, opts ...Option) ([]byte, error) {
+	c := newConverter(opts)
+	doc, front, err := c.parseInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &mdWriter{c: c, used: make(map[*linkRef]bool)}
+	blocks := make([]string, 0)
+	if len(front) > 0 {
+		blocks = append(blocks, string(bytes.Join(front, nl)))
+	}
+	blocks = append(blocks, w.blocks(doc)...)
+	blocks = append(blocks, w.unreferenced()...)
+	if defs := w.linkDefs(); defs != "" {
+		blocks = append(blocks, defs)
+	}
+	if len(blocks) == 0 {
+		return []byte{}, nil
+	}
+
+	return []byte(strings.Join(blocks, "\n\n") + "\n"), nil
+}
+
+// breakable marks the spaces of text where a paragraph may be wrapped
+const breakable = "\x00"
+
+type mdWriter struct {
+	c    *converter
+	refs []*linkRef // link references in order of use
+	used map[*linkRef]bool
+	cell bool // writing a table cell, where | is escaped
+}
+
+// blocks returns the markdown of the blocks of parent
+func (w *mdWriter) blocks(parent ast.Node) []string {
+	ret := make([]string, 0)
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		if fns, ok := n.(*ast.Footnotes); ok {
+			for fn := fns.FirstChild(); fn != nil; fn = fn.NextSibling() {
+				ret = append(ret, w.footnote(fn))
+			}
+			continue
+		}
+		if b := w.block(n); b != "" {
+			ret = append(ret, b)
+		}
+	}
+	return ret
+}
+
+func (w *mdWriter) block(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Heading:
+		s := strings.Repeat("#", n.Level) + " " + w.line(n)
+		if n.ID != "" {
+			s += " {#" + n.ID + "}"
+		}
+		return s
+	case *ast.Blockquote:
+		return strings.Repeat(">", n.Level) + " " + w.line(n)
+	case *ast.List:
+		lines := make([]string, 0)
+		w.list(n, 0, &lines)
+		return strings.Join(lines, "\n")
+	case *ast.Table:
+		return w.table(n)
+	case *ast.CodeBlock:
+		return "```" + string(n.Lang) + "\n" + html.UnescapeString(string(n.Code)) + "```"
+	case *ast.Paragraph:
+		if !w.c.softBreaks {
+			return w.line(n)
+		}
+		return wrap(w.inline(n), w.c.wrap)
+	case *ast.HTMLBlock:
+		return string(n.Raw)
+	case *ast.TOC:
+		if s := w.source(n); s != "" {
+			return s
+		}
+		return "[TOC]"
+	}
+
+	// custom nodes
+	return w.source(n)
+}
+
+func (w *mdWriter) list(l ast.Node, depth int, lines *[]string) {
+	marker := listMarker(l, "- ")
+	for n := l.FirstChild(); n != nil; n = n.NextSibling() {
+		if n.Kind() == ast.KindList {
+			w.list(n, depth+1, lines)
+			continue
+		}
+		*lines = append(*lines, strings.Repeat("  ", depth)+marker()+w.line(n))
+	}
+}
+
+// listMarker returns a function which returns the marker of the next item
+// of l: bullet for an unordered list, the number for an ordered one
+func listMarker(l ast.Node, bullet string) func() string {
+	list, ok := l.(*ast.List)
+	if !ok || !list.Ordered {
+		return func() string { return bullet }
+	}
+	num := list.Start - 1
+	return func() string {
+		num++
+		return fmt.Sprintf("%d. ", num)
+	}
+}
+
+// table writes the rows of t with the columns padded to the same width
+func (w *mdWriter) table(t *ast.Table) string {
+	w.cell = true
+	rows := make([][]string, 0)
+	for row := t.FirstChild(); row != nil; row = row.NextSibling() {
+		cells := make([]string, 0)
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cells = append(cells, w.line(cell))
+		}
+		rows = append(rows, cells)
+	}
+	w.cell = false
+
+	align := tableAlign(t)
+	widths := make([]int, len(align))
+	for i := range widths {
+		widths[i] = 3
+		for _, cells := range rows {
+			if size := utf8.RuneCountInString(cells[i]); size > widths[i] {
+				widths[i] = size
+			}
+		}
+	}
+
+	delims := make([]string, len(align))
+	for i, a := range align {
+		d := strings.Repeat("-", widths[i])
+		switch a {
+		case "left":
+			d = ":" + d[1:]
+		case "center":
+			d = ":" + d[2:] + ":"
+		case "right":
+			d = d[1:] + ":"
+		}
+		delims[i] = d
+	}
+
+	lines := make([]string, 0, len(rows)+1)
+	for i, cells := range rows {
+		for j, cell := range cells {
+			cells[j] = cell + strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell))
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "| "+strings.Join(delims, " | ")+" |")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// footnote writes the definition of a referenced footnote.
+// Lines following the first are indented by four spaces.
+func (w *mdWriter) footnote(n ast.Node) string {
+	fn := n.(*ast.Footnote)
+	s := fmt.Sprintf("[^%s]: %s", w.footnoteLabel(fn.Num), strings.Join(w.blocks(fn), "\n\n"))
+	return indent(strings.TrimRight(s, " "))
+}
+
+// unreferenced writes the footnote definitions without references as they
+// are in the source
+func (w *mdWriter) unreferenced() []string {
+	ret := make([]string, 0)
+	for _, fn := range w.c.fns.defined {
+		if fn.num > 0 {
+			continue
+		}
+		s := fmt.Sprintf("[^%s]: %s", fn.label, bytes.Join(fn.lines, nl))
+		ret = append(ret, indent(strings.TrimRight(s, " ")))
+	}
+	return ret
+}
+
+func (w *mdWriter) footnoteLabel(num int) string {
+	if num > 0 && num <= len(w.c.fns.order) {
+		return w.c.fns.order[num-1].label
+	}
+	return fmt.Sprint(num)
+}
+
+// linkDefs writes the link reference definitions, the used ones first
+func (w *mdWriter) linkDefs() string {
+	refs := w.refs
+	for _, ref := range w.c.refs.order {
+		if !w.used[ref] {
+			refs = append(refs, ref)
+		}
+	}
+	lines := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		lines = append(lines, fmt.Sprintf("[%s]: %s", ref.label, ref.dest))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// line returns the inline markdown of n on one line
+func (w *mdWriter) line(n ast.Node) string {
+	return strings.Replace(w.inline(n), breakable, " ", -1)
+}
+
+// inline returns the markdown of the inline nodes of parent
+func (w *mdWriter) inline(parent ast.Node) string {
+	var b strings.Builder
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		b.WriteString(w.inlineNode(n))
+	}
+	return b.String()
+}
+
+func (w *mdWriter) inlineNode(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Text:
+		if w.cell {
+			return strings.NewReplacer(" ", breakable, "|", `\|`).Replace(string(n.Value))
+		}
+		return strings.NewReplacer(" ", breakable, "\n", breakable).Replace(string(n.Value))
+	case *ast.Emphasis:
+		d := w.delim(n)
+		return d + w.inline(n) + d
+	case *ast.Strong:
+		d := w.delim(n)
+		return d + d + w.inline(n) + d + d
+	case *ast.Strikethrough:
+		return "~~" + w.inline(n) + "~~"
+	case *ast.CodeSpan:
+		if s := w.source(n); s != "" {
+			return s
+		}
+		fence := codeFence(string(n.Code))
+		return fence + string(n.Code) + fence
+	case *ast.Link:
+		text := w.inline(n)
+		if n.Ref != "" {
+			return "[" + text + "]" + w.refLabel(n.Ref, text)
+		}
+		return "[" + text + "](" + string(n.Dest) + ")"
+	case *ast.Image:
+		if n.Ref != "" {
+			return "![" + string(n.Alt) + "]" + w.refLabel(n.Ref, string(n.Alt))
+		}
+		return "![" + string(n.Alt) + "](" + string(n.Dest) + ")"
+	case *ast.FootnoteRef:
+		return "[^" + w.footnoteLabel(n.Num) + "]"
+	}
+
+	// custom nodes
+	return w.source(n)
+}
+
+// refLabel returns the [label] part of a reference link with text,
+// which is empty when the label is the text
+func (w *mdWriter) refLabel(label, text string) string {
+	if ref := w.c.refs.lookup([]byte(label)); ref != nil && !w.used[ref] {
+		w.used[ref] = true
+		w.refs = append(w.refs, ref)
+	}
+	text = strings.Replace(text, breakable, " ", -1)
+	if normalizeLabel([]byte(label)) == normalizeLabel([]byte(text)) {
+		return "[]"
+	}
+	return "[" + label + "]"
+}
+
+// delim returns the delimiter emphasis n was written with
+func (w *mdWriter) delim(n ast.Node) string {
+	if s := w.source(n); strings.HasPrefix(s, "_") {
+		return "_"
+	}
+	return "*"
+}
+
+// source returns the source of n, or an empty string for nodes without
+func (w *mdWriter) source(n ast.Node) string {
+	s := n.Span()
+	if s.IsZero() {
+		return ""
+	}
+	return string(w.c.src.buf[s.Start.Offset:s.End.Offset])
+}
+
+// codeFence returns a backtick run longer than any in code
+func codeFence(code string) string {
+	fence := "`"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence
+}
+
+// indent indents the lines of s following the first by four spaces
+func indent(s string) string {
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = "    " + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// a line starting with one of these would not continue a paragraph
+var blockStartExp = regexp.MustCompile(`^([#>=*+\-<\[|:]|` + "```" + `|\d+[.)]$)`)
+
+// wrap breaks s at breakable spaces so that lines fit in width where
+// possible. A width of 0 keeps s on one line.
+func wrap(s string, width int) string {
+	if width <= 0 {
+		return strings.Replace(s, breakable, " ", -1)
+	}
+
+	var b strings.Builder
+	n := 0
+	for i, word := range strings.Split(s, breakable) {
+		size := utf8.RuneCountInString(word)
+		if i > 0 {
+			if n > 0 && size > 0 && n+1+size > width && !blockStartExp.MatchString(word) {
+				b.WriteString("\n")
+				n = 0
+			} else {
+				b.WriteString(" ")
+				n++
+			}
+		}
+		b.WriteString(word)
+		n += size
+	}
+	return b.String()
+}
@@ -0,0 +1,149 @@
+package gom2h
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// plain text
+//
+// PlainText writes the text of a document without markup, e.g. for search
+// indexes and previews. Links and images are replaced by their text, list
+// items get bullets or numbers, table cells are separated by tabs and
+// footnotes are listed at the bottom.
+
+// WithoutCodeBlocks drops code blocks from plain text
+func WithoutCodeBlocks() Option {
+	return func(c *converter) {
+		c.noCode = true
+	}
+}
+
+// WithTruncate truncates plain text to n characters at a word boundary.
+// Truncated text ends with an ellipsis.
+func WithTruncate(n int) Option {
+	return func(c *converter) {
+		c.truncate = n
+	}
+}
+
+// PlainText converts markdown to plain text
+func PlainText(input []byte, opts ...Option) ([]byte, error) {
+	c := newConverter(opts)
+	doc, err := c.convert(input)
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.Join(c.textBlocks(doc, 0), "\n\n")
+	if c.truncate > 0 {
+		text = truncate(text, c.truncate)
+	}
+	return []byte(text), nil
+}
+
+// textBlocks returns the text of the blocks of parent, with list items
+// indented by depth
+func (c *converter) textBlocks(parent ast.Node, depth int) []string {
+	ret := make([]string, 0)
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		var s string
+		switch n := n.(type) {
+		case *ast.List:
+			s = strings.Join(c.textList(n, depth), "\n")
+		case *ast.Table:
+			rows := make([]string, 0)
+			for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+				cells := make([]string, 0)
+				for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+					cells = append(cells, c.textInline(cell))
+				}
+				rows = append(rows, strings.Join(cells, "\t"))
+			}
+			s = strings.Join(rows, "\n")
+		case *ast.CodeBlock:
+			if !c.noCode {
+				s = strings.TrimSuffix(html.UnescapeString(string(n.Code)), "\n")
+			}
+		case *ast.HTMLBlock:
+			s = stripTags(string(n.Raw))
+		case *ast.TOC:
+		case *ast.Footnotes:
+			s = strings.Join(c.textBlocks(n, depth), "\n")
+		case *ast.Footnote:
+			s = fmt.Sprintf("[%d] %s", n.Num, strings.Join(c.textBlocks(n, depth), " "))
+		default:
+			if n.FirstChild() != nil && !n.FirstChild().Kind().IsBlock() {
+				s = c.textInline(n)
+			} else {
+				s = strings.Join(c.textBlocks(n, depth), "\n\n")
+			}
+		}
+		if s != "" {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+func (c *converter) textList(l ast.Node, depth int) []string {
+	lines := make([]string, 0)
+	marker := listMarker(l, "• ")
+	for n := l.FirstChild(); n != nil; n = n.NextSibling() {
+		if n.Kind() == ast.KindList {
+			lines = append(lines, c.textList(n, depth+1)...)
+			continue
+		}
+		lines = append(lines, strings.Repeat("  ", depth)+marker()+c.textInline(n))
+	}
+	return lines
+}
+
+// textInline returns the text of the inline nodes of parent
+func (c *converter) textInline(parent ast.Node) string {
+	var b strings.Builder
+	ast.Walk(parent, func(n ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			b.WriteString(stripTags(string(n.Value)))
+		case *ast.CodeSpan:
+			b.WriteString(string(n.Code))
+		case *ast.Image:
+			b.WriteString(string(n.Alt))
+		case *ast.FootnoteRef:
+			fmt.Fprintf(&b, "[%d]", n.Num)
+		}
+		return ast.WalkContinue
+	})
+	return b.String()
+}
+
+func stripTags(s string) string {
+	return html.UnescapeString(tagExp.ReplaceAllString(s, ""))
+}
+
+// truncate cuts s to at most n characters with the ellipsis, at the last
+// space or in the middle of a word too long to fit
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	runes := []rune(s)
+	cut := n - 1
+	for i := cut; i > 0; i-- {
+		if unicode.IsSpace(runes[i]) {
+			cut = i
+			break
+		}
+	}
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + "…"
+}
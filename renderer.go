@@ -0,0 +1,279 @@
+package gom2h
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// Renderer renders the nodes of a parsed document.
+// The children of a node are rendered first and passed as text, items
+// or body.
+type Renderer interface {
+	// block
+	Heading(level int, id string, text []byte) []byte
+	Blockquote(level int, text []byte) []byte
+	List(items []byte) []byte
+	OrderedList(start int, items []byte) []byte
+	ListItem(text []byte) []byte
+	CodeBlock(lang, code []byte) []byte
+	Paragraph(text []byte) []byte
+	HTMLBlock(raw []byte) []byte
+	TOC(headings []Heading) []byte
+	Footnotes(items []byte) []byte
+	Footnote(num, refs int, body []byte) []byte
+	// Table gets the rendered header row and the other rows separated by
+	// newlines
+	Table(header, body []byte) []byte
+	TableRow(cells []byte) []byte
+	TableCell(header bool, align string, text []byte) []byte
+
+	// inline
+	Text(text []byte) []byte
+	Emphasis(text []byte) []byte
+	Strong(text []byte) []byte
+	Strikethrough(text []byte) []byte
+	CodeSpan(code []byte) []byte
+	Link(href, text []byte) []byte
+	Image(src, alt []byte) []byte
+	FootnoteRef(num, ref int) []byte
+}
+
+// WithRenderer renders the document with r instead of the default HTMLRenderer
+func WithRenderer(r Renderer) Option {
+	return func(c *converter) {
+		c.r = r
+	}
+}
+
+// HTMLRenderer is the default Renderer.
+// Embed it in a struct to override the output of single nodes:
+//
+//	type lazyImages struct{ gom2h.HTMLRenderer }
+//
+//	func (lazyImages) Image(src, alt []byte) []byte {
+//		return []byte(fmt.Sprintf(`<img src="%s" alt="%s" loading="lazy" />`, src, alt))
+//	}
+type HTMLRenderer struct {
+	// HeadingAnchors adds a hover anchor link to each heading
+	HeadingAnchors bool
+}
+
+func (r HTMLRenderer) Heading(level int, id string, text []byte) []byte {
+	var anchor string
+	if r.HeadingAnchors {
+		anchor = fmt.Sprintf(`<a class="anchor" href="#%s" aria-hidden="true"><span class="octicon octicon-link"></span></a>`, html.EscapeString(id))
+	}
+	return []byte(fmt.Sprintf(`<h%d id="%s">%s%s</h%d>`, level, html.EscapeString(id), anchor, text, level))
+}
+
+func (r HTMLRenderer) Blockquote(level int, text []byte) []byte {
+	return []byte(fmt.Sprintf(`%s<p>%s</p>%s`, strings.Repeat(`<blockquote>`, level), text, strings.Repeat(`</blockquote>`, level)))
+}
+
+// List wraps items, which are rendered list items and nested lists
+// separated by newlines
+func (r HTMLRenderer) List(items []byte) []byte {
+	return []byte(fmt.Sprintf("<ul>\n%s\n</ul>", items))
+}
+
+// OrderedList wraps items like List, numbered from start
+func (r HTMLRenderer) OrderedList(start int, items []byte) []byte {
+	if start != 1 {
+		return []byte(fmt.Sprintf("<ol start=\"%d\">\n%s\n</ol>", start, items))
+	}
+	return []byte(fmt.Sprintf("<ol>\n%s\n</ol>", items))
+}
+
+func (r HTMLRenderer) ListItem(text []byte) []byte {
+	return []byte(fmt.Sprintf(`<li>%s</li>`, text))
+}
+
+func (r HTMLRenderer) CodeBlock(lang, code []byte) []byte {
+	if lang != nil {
+		return []byte(fmt.Sprintf(`<pre><code class="%s">%s</code></pre>`, lang, code))
+	}
+	return []byte(fmt.Sprintf(`<pre><code>%s</code></pre>`, code))
+}
+
+func (r HTMLRenderer) Paragraph(text []byte) []byte {
+	return []byte(fmt.Sprintf(`<p>%s</p>`, text))
+}
+
+func (r HTMLRenderer) HTMLBlock(raw []byte) []byte {
+	return raw
+}
+
+func (r HTMLRenderer) TOC(headings []Heading) []byte {
+	return TOC(headings)
+}
+
+func (r HTMLRenderer) Footnotes(items []byte) []byte {
+	return []byte(fmt.Sprintf("<section class=\"footnotes\">\n<ol>\n%s\n</ol>\n</section>", items))
+}
+
+// Footnote adds links back to the references to the last paragraph of body
+func (r HTMLRenderer) Footnote(num, refs int, body []byte) []byte {
+	var backrefs string
+	for ref := 1; ref <= refs; ref++ {
+		backrefs += fmt.Sprintf(` <a href="#%s" class="footnote-backref">&#8617;`, fnrefID(num, ref))
+		if ref > 1 {
+			backrefs += fmt.Sprintf(`<sup>%d</sup>`, ref)
+		}
+		backrefs += `</a>`
+	}
+	if bytes.HasSuffix(body, []byte("</p>")) {
+		body = []byte(fmt.Sprintf("%s%s</p>", body[:len(body)-len("</p>")], backrefs))
+	} else {
+		body = []byte(fmt.Sprintf("%s\n<p>%s</p>", body, strings.TrimSpace(backrefs)))
+	}
+
+	return []byte(fmt.Sprintf("<li id=\"fn-%d\">\n%s\n</li>", num, body))
+}
+
+func (r HTMLRenderer) Table(header, body []byte) []byte {
+	if len(body) == 0 {
+		return []byte(fmt.Sprintf("<table>\n<thead>\n%s\n</thead>\n</table>", header))
+	}
+	return []byte(fmt.Sprintf("<table>\n<thead>\n%s\n</thead>\n<tbody>\n%s\n</tbody>\n</table>", header, body))
+}
+
+func (r HTMLRenderer) TableRow(cells []byte) []byte {
+	return []byte(fmt.Sprintf("<tr>\n%s\n</tr>", cells))
+}
+
+func (r HTMLRenderer) TableCell(header bool, align string, text []byte) []byte {
+	tag := "td"
+	if header {
+		tag = "th"
+	}
+	if align != "" {
+		return []byte(fmt.Sprintf(`<%s align="%s">%s</%s>`, tag, align, text, tag))
+	}
+	return []byte(fmt.Sprintf(`<%s>%s</%s>`, tag, text, tag))
+}
+
+func (r HTMLRenderer) Text(text []byte) []byte {
+	return text
+}
+
+func (r HTMLRenderer) Emphasis(text []byte) []byte {
+	return []byte(fmt.Sprintf(`<em>%s</em>`, text))
+}
+
+func (r HTMLRenderer) Strong(text []byte) []byte {
+	return []byte(fmt.Sprintf(`<strong>%s</strong>`, text))
+}
+
+func (r HTMLRenderer) Strikethrough(text []byte) []byte {
+	return []byte(fmt.Sprintf(`<del>%s</del>`, text))
+}
+
+func (r HTMLRenderer) CodeSpan(code []byte) []byte {
+	return []byte(fmt.Sprintf(`<code>%s</code>`, code))
+}
+
+func (r HTMLRenderer) Link(href, text []byte) []byte {
+	return []byte(fmt.Sprintf(`<a href="%s">%s</a>`, href, text))
+}
+
+func (r HTMLRenderer) Image(src, alt []byte) []byte {
+	return []byte(fmt.Sprintf(`<img src="%s" alt="%s" />`, src, alt))
+}
+
+func (r HTMLRenderer) FootnoteRef(num, ref int) []byte {
+	return []byte(fmt.Sprintf(`<sup id="%s"><a href="#fn-%d" class="footnote-ref">%d</a></sup>`, fnrefID(num, ref), num, num))
+}
+
+// render renders n with the render hook for its kind or the renderer
+func (c *converter) render(n ast.Node) []byte {
+	children := c.renderChildren(n)
+	if hook, ok := c.hooks[n.Kind()]; ok {
+		return hook(c.r, n, children)
+	}
+
+	switch n := n.(type) {
+	case *ast.Heading:
+		return c.r.Heading(n.Level, n.ID, children)
+	case *ast.Blockquote:
+		return c.r.Blockquote(n.Level, children)
+	case *ast.List:
+		if n.Ordered {
+			return c.r.OrderedList(n.Start, children)
+		}
+		return c.r.List(children)
+	case *ast.ListItem:
+		return c.r.ListItem(children)
+	case *ast.CodeBlock:
+		return c.r.CodeBlock(n.Lang, n.Code)
+	case *ast.Paragraph:
+		return c.r.Paragraph(children)
+	case *ast.HTMLBlock:
+		return c.r.HTMLBlock(n.Raw)
+	case *ast.TOC:
+		return c.r.TOC(c.headings)
+	case *ast.Footnotes:
+		return c.r.Footnotes(children)
+	case *ast.Footnote:
+		return c.r.Footnote(n.Num, n.Refs, children)
+	case *ast.Table:
+		// the first row is the header
+		var header, body []byte
+		for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+			if row == n.FirstChild() {
+				header = c.render(row)
+				continue
+			}
+			if len(body) > 0 {
+				body = newline(body)
+			}
+			body = append(body, c.render(row)...)
+		}
+		return c.r.Table(header, body)
+	case *ast.TableRow:
+		return c.r.TableRow(children)
+	case *ast.TableCell:
+		header := false
+		if row, ok := n.Parent().(*ast.TableRow); ok {
+			header = row.Header
+		}
+		return c.r.TableCell(header, n.Align, children)
+	case *ast.Text:
+		return c.r.Text(n.Value)
+	case *ast.Emphasis:
+		return c.r.Emphasis(children)
+	case *ast.Strong:
+		return c.r.Strong(children)
+	case *ast.Strikethrough:
+		return c.r.Strikethrough(children)
+	case *ast.CodeSpan:
+		return c.r.CodeSpan(n.Code)
+	case *ast.Link:
+		return c.r.Link(n.Dest, children)
+	case *ast.Image:
+		return c.r.Image(n.Dest, n.Alt)
+	case *ast.FootnoteRef:
+		return c.r.FootnoteRef(n.Num, n.Ref)
+	}
+
+	// the document and custom nodes without a render hook
+	return children
+}
+
+// renderChildren renders the children of n, putting blocks on separate lines
+func (c *converter) renderChildren(n ast.Node) []byte {
+	ret := make([]byte, 0)
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		// a code block is not followed by a newline
+		prev := child.PrevSibling()
+		if prev != nil && child.Kind().IsBlock() &&
+			(prev.Kind() != ast.KindCodeBlock || child.Kind() == ast.KindFootnotes) {
+			ret = newline(ret)
+		}
+		ret = append(ret, c.render(child)...)
+	}
+	return ret
+}
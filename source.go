@@ -0,0 +1,115 @@
+package gom2h
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// source positions
+//
+// Lines and inline text are subslices of the input, so their position is
+// found from the slice itself: its offset is the distance of its first byte
+// from the first byte of the input.
+
+// ParseError is a syntax error at a position of the source
+type ParseError struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Col, e.Msg)
+}
+
+// WithStrict reports unclosed code fences, references to undefined
+// footnotes and invalid front matter as a *ParseError. Without it, invalid
+// front matter is converted as markdown.
+func WithStrict() Option {
+	return func(c *converter) {
+		c.strict = true
+	}
+}
+
+type source struct {
+	buf   []byte
+	lines []int // offsets of the line starts
+}
+
+func newSource(buf []byte) *source {
+	s := &source{buf: buf, lines: []int{0}}
+	for i, b := range buf {
+		if b == '\n' {
+			s.lines = append(s.lines, i+1)
+		}
+	}
+	return s
+}
+
+// offset returns the offset of b in the source, or -1 if b is empty or
+// not a subslice of it
+func (s *source) offset(b []byte) int {
+	if len(b) == 0 || len(s.buf) == 0 {
+		return -1
+	}
+	off := int(reflect.ValueOf(b).Pointer()) - int(reflect.ValueOf(s.buf).Pointer())
+	if off < 0 || off+len(b) > len(s.buf) {
+		return -1
+	}
+	return off
+}
+
+func (s *source) position(off int) ast.Position {
+	l := sort.SearchInts(s.lines, off+1) - 1
+	return ast.Position{Offset: off, Line: l + 1, Col: off - s.lines[l] + 1}
+}
+
+// span returns the span of b, or a zero span if b is not from the source
+func (s *source) span(b []byte) ast.Span {
+	off := s.offset(b)
+	if off < 0 {
+		return ast.Span{}
+	}
+	return ast.Span{Start: s.position(off), End: s.position(off + len(b))}
+}
+
+// spanOf returns the span from the start of first to the end of last
+func (s *source) spanOf(first, last []byte) ast.Span {
+	start, end := s.span(first), s.span(last)
+	if start.IsZero() || end.IsZero() {
+		return ast.Span{}
+	}
+	return ast.Span{Start: start.Start, End: end.End}
+}
+
+// join returns lines joined by newlines. Consecutive lines of the source
+// are joined as a subslice of it, so that their inline nodes have spans.
+func (s *source) join(lines [][]byte) []byte {
+	joined := bytes.Join(lines, nl)
+	start := s.offset(lines[0])
+	if start >= 0 && start+len(joined) <= len(s.buf) && bytes.Equal(s.buf[start:start+len(joined)], joined) {
+		return s.buf[start : start+len(joined)]
+	}
+	return joined
+}
+
+// errorAt returns a ParseError at the start of b
+func (s *source) errorAt(b []byte, format string, a ...interface{}) *ParseError {
+	pos := s.span(b).Start
+	return &ParseError{Line: pos.Line, Col: pos.Col, Msg: fmt.Sprintf(format, a...)}
+}
+
+// extend makes the span of n reach the end of span
+func extend(n ast.Node, span ast.Span) {
+	if n.Span().IsZero() {
+		n.SetSpan(span)
+		return
+	}
+	if !span.IsZero() {
+		n.SetSpan(ast.Span{Start: n.Span().Start, End: span.End})
+	}
+}
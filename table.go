@@ -0,0 +1,120 @@
+package gom2h
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// tables
+//
+// A line of cells followed by a delimiter row opens a table:
+//
+//	| Name | Size |
+//	| :--- | ---: |
+//	| a    | 1    |
+//
+// Colons in the delimiter row align the columns. The following lines with
+// a | are rows until a line without one. A row has as many cells as the
+// header, missing cells are empty and extra cells are dropped. \| is a pipe
+// in a cell, and pipes in code spans do not separate cells.
+
+var tableDelimExp = regexp.MustCompile(`^ *\|? *:?-+:? *(\| *:?-+:? *)*\|? *$`)
+
+// table returns the table opened by the header line and the delimiter
+// line, or nil if they do not open a table
+func (c *converter) table(header, delim []byte) *ast.Table {
+	if !tableDelimExp.Match(delim) || bytes.IndexByte(header, '|') < 0 && bytes.IndexByte(delim, '|') < 0 {
+		return nil
+	}
+	cols := splitCells(delim)
+	if len(splitCells(header)) != len(cols) {
+		return nil
+	}
+
+	align := make([]string, len(cols))
+	for i, col := range cols {
+		left, right := col[0] == ':', col[len(col)-1] == ':'
+		switch {
+		case left && right:
+			align[i] = "center"
+		case left:
+			align[i] = "left"
+		case right:
+			align[i] = "right"
+		}
+	}
+
+	t := &ast.Table{}
+	c.tableRow(t, header, align, true)
+	extend(t, c.src.span(delim))
+	return t
+}
+
+// tableRow appends the row of line with a cell per column to t
+func (c *converter) tableRow(t *ast.Table, line []byte, align []string, header bool) {
+	row := &ast.TableRow{Header: header}
+	row.SetSpan(c.src.span(line))
+	cells := splitCells(line)
+	for i := range align {
+		cell := &ast.TableCell{Align: align[i]}
+		if i < len(cells) {
+			text := cells[i]
+			cell.SetSpan(c.src.span(text))
+			if bytes.Contains(text, []byte(`\|`)) {
+				text = bytes.Replace(text, []byte(`\|`), []byte("|"), -1)
+			}
+			c.parseInline(cell, text)
+		}
+		ast.AppendChild(row, cell)
+	}
+	ast.AppendChild(t, row)
+	extend(t, row.Span())
+}
+
+// tableAlign returns the alignment of the columns of t
+func tableAlign(t *ast.Table) []string {
+	align := make([]string, 0)
+	if row := t.FirstChild(); row != nil {
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			align = append(align, cell.(*ast.TableCell).Align)
+		}
+	}
+	return align
+}
+
+// splitCells returns the trimmed cells of a table line
+func splitCells(line []byte) [][]byte {
+	line = bytes.TrimSpace(line)
+	if len(line) > 0 && line[0] == '|' {
+		line = line[1:]
+	}
+
+	cells := make([][]byte, 0)
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '`':
+			// skip the code span
+			j := i
+			for j < len(line) && line[j] == '`' {
+				j++
+			}
+			if end := bytes.Index(line[j:], line[i:j]); end >= 0 {
+				j += end + j - i
+			}
+			i = j - 1
+		case '|':
+			cells = append(cells, bytes.TrimSpace(line[start:i]))
+			start = i + 1
+		}
+	}
+	// a trailing pipe does not start a cell
+	if rest := bytes.TrimSpace(line[start:]); len(rest) > 0 || start == 0 {
+		cells = append(cells, rest)
+	}
+	return cells
+}
@@ -0,0 +1,244 @@
+package gom2h
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// terminal
+//
+// Terminal writes a document for reading in a terminal. Headings are bold
+// and coloured, emphasis uses the terminal's text attributes, code blocks
+// are dimmed, blockquotes get a bar and tables are drawn in boxes. Styles
+// are switched off with their own reset codes, so that they nest and
+// survive wrapped lines.
+
+// WithoutColor writes terminal output without ANSI escape sequences
+func WithoutColor() Option {
+	return func(c *converter) {
+		c.noColor = true
+	}
+}
+
+var ansiExp = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+const (
+	ansiHeading    = "\x1b[1;36m"
+	ansiHeadingOff = "\x1b[22;39m"
+	ansiBold       = "\x1b[1m"
+	ansiBoldOff    = "\x1b[22m"
+	ansiItalic     = "\x1b[3m"
+	ansiItalicOff  = "\x1b[23m"
+	ansiUnder      = "\x1b[4m"
+	ansiUnderOff   = "\x1b[24m"
+	ansiStrike     = "\x1b[9m"
+	ansiStrikeOff  = "\x1b[29m"
+	ansiCode       = "\x1b[33m"
+	ansiCodeOff    = "\x1b[39m"
+	ansiDim        = "\x1b[2m"
+	ansiDimOff     = "\x1b[22m"
+)
+
+// Terminal converts markdown to text styled with ANSI escape sequences.
+// Paragraphs are wrapped at the width set with WithWrap.
+func Terminal(input []byte, opts ...Option) ([]byte, error) {
+	c := newConverter(opts)
+	doc, err := c.convert(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(strings.Join(c.termBlocks(doc, ""), "\n\n") + "\n"), nil
+}
+
+// termBlocks returns the blocks of parent with each line prefixed
+func (c *converter) termBlocks(parent ast.Node, prefix string) []string {
+	ret := make([]string, 0)
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		var lines []string
+		switch n := n.(type) {
+		case *ast.Heading:
+			text := strings.Repeat("#", n.Level) + breakable + c.termInline(n)
+			lines = c.termWrap(c.ansi(text, ansiHeading, ansiHeadingOff), prefix, prefix)
+		case *ast.Blockquote:
+			bar := prefix + strings.Repeat(c.ansi("│", ansiDim, ansiDimOff)+" ", n.Level)
+			lines = c.termWrap(c.termInline(n), bar, bar)
+		case *ast.List:
+			lines = c.termList(n, prefix)
+		case *ast.Table:
+			lines = c.termTable(n, prefix)
+		case *ast.CodeBlock:
+			code := strings.TrimSuffix(html.UnescapeString(string(n.Code)), "\n")
+			for _, line := range strings.Split(code, "\n") {
+				lines = append(lines, prefix+"  "+c.ansi(line, ansiDim, ansiDimOff))
+			}
+		case *ast.HTMLBlock:
+			lines = c.termWrap(stripTags(string(n.Raw)), prefix, prefix)
+		case *ast.TOC:
+			for _, h := range c.headings {
+				lines = append(lines, prefix+strings.Repeat("  ", h.Level-1)+"• "+h.Text)
+			}
+		case *ast.Footnotes:
+			lines = append(lines, prefix+c.ansi(strings.Repeat("─", 8), ansiDim, ansiDimOff))
+			lines = append(lines, c.termBlocks(n, prefix)...)
+		case *ast.Footnote:
+			label := fmt.Sprintf("[%d] ", n.Num)
+			body := strings.Join(c.termBlocks(n, prefix+strings.Repeat(" ", len(label))), "\n")
+			lines = []string{prefix + label + strings.TrimPrefix(body, prefix+strings.Repeat(" ", len(label)))}
+		default:
+			if n.FirstChild() != nil && !n.FirstChild().Kind().IsBlock() {
+				lines = c.termWrap(c.termInline(n), prefix, prefix)
+			} else {
+				lines = c.termBlocks(n, prefix)
+			}
+		}
+		if len(lines) > 0 {
+			ret = append(ret, strings.Join(lines, "\n"))
+		}
+	}
+	return ret
+}
+
+func (c *converter) termList(l ast.Node, prefix string) []string {
+	lines := make([]string, 0)
+	marker := listMarker(l, "• ")
+	for n := l.FirstChild(); n != nil; n = n.NextSibling() {
+		if n.Kind() == ast.KindList {
+			lines = append(lines, c.termList(n, prefix+"  ")...)
+			continue
+		}
+		m := marker()
+		lines = append(lines, c.termWrap(c.termInline(n), prefix+m, prefix+strings.Repeat(" ", utf8.RuneCountInString(m)))...)
+	}
+	return lines
+}
+
+// termTable draws the rows of t in a box, the header row separated from
+// the body. Cells are not wrapped.
+func (c *converter) termTable(t *ast.Table, prefix string) []string {
+	rows := make([][]string, 0)
+	for row := t.FirstChild(); row != nil; row = row.NextSibling() {
+		cells := make([]string, 0)
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			text := strings.Replace(c.termInline(cell), breakable, " ", -1)
+			if row.(*ast.TableRow).Header {
+				text = c.ansi(text, ansiBold, ansiBoldOff)
+			}
+			cells = append(cells, text)
+		}
+		rows = append(rows, cells)
+	}
+
+	align := tableAlign(t)
+	widths := make([]int, len(align))
+	for _, cells := range rows {
+		for i, cell := range cells {
+			if size := visibleLen(cell); size > widths[i] {
+				widths[i] = size
+			}
+		}
+	}
+
+	border := func(left, middle, right string) string {
+		parts := make([]string, len(widths))
+		for i, w := range widths {
+			parts[i] = strings.Repeat("─", w+2)
+		}
+		return prefix + c.ansi(left+strings.Join(parts, middle)+right, ansiDim, ansiDimOff)
+	}
+	bar := c.ansi("│", ansiDim, ansiDimOff)
+
+	lines := []string{border("┌", "┬", "┐")}
+	for i, cells := range rows {
+		if i == 1 {
+			lines = append(lines, border("├", "┼", "┤"))
+		}
+		line := prefix + bar
+		for j, cell := range cells {
+			pad := widths[j] - visibleLen(cell)
+			switch align[j] {
+			case "right":
+				cell = strings.Repeat(" ", pad) + cell
+			case "center":
+				cell = strings.Repeat(" ", pad/2) + cell + strings.Repeat(" ", pad-pad/2)
+			default:
+				cell += strings.Repeat(" ", pad)
+			}
+			line += " " + cell + " " + bar
+		}
+		lines = append(lines, line)
+	}
+	return append(lines, border("└", "┴", "┘"))
+}
+
+// termInline returns the styled inline nodes of parent with breakable
+// spaces
+func (c *converter) termInline(parent ast.Node) string {
+	var b strings.Builder
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		switch n := n.(type) {
+		case *ast.Text:
+			b.WriteString(strings.NewReplacer(" ", breakable, "\n", breakable).Replace(stripTags(string(n.Value))))
+		case *ast.Emphasis:
+			b.WriteString(c.ansi(c.termInline(n), ansiItalic, ansiItalicOff))
+		case *ast.Strong:
+			b.WriteString(c.ansi(c.termInline(n), ansiBold, ansiBoldOff))
+		case *ast.Strikethrough:
+			b.WriteString(c.ansi(c.termInline(n), ansiStrike, ansiStrikeOff))
+		case *ast.CodeSpan:
+			b.WriteString(c.ansi(string(n.Code), ansiCode, ansiCodeOff))
+		case *ast.Link:
+			text := c.termInline(n)
+			b.WriteString(c.ansi(text, ansiUnder, ansiUnderOff))
+			if plain := strings.Replace(ansiExp.ReplaceAllString(text, ""), breakable, " ", -1); plain != string(n.Dest) {
+				b.WriteString(breakable + c.ansi("("+string(n.Dest)+")", ansiDim, ansiDimOff))
+			}
+		case *ast.Image:
+			b.WriteString(c.ansi("[image: "+string(n.Alt)+"]", ansiDim, ansiDimOff))
+		case *ast.FootnoteRef:
+			fmt.Fprintf(&b, "[%d]", n.Num)
+		default:
+			b.WriteString(c.termInline(n))
+		}
+	}
+	return b.String()
+}
+
+func (c *converter) ansi(s, on, off string) string {
+	if c.noColor {
+		return s
+	}
+	return on + s + off
+}
+
+// termWrap wraps text at breakable spaces to the width of the converter,
+// starting the first line with first and the following lines with rest
+func (c *converter) termWrap(text, first, rest string) []string {
+	lines := make([]string, 0)
+	line, n := first, visibleLen(first)
+	for i, word := range strings.Split(text, breakable) {
+		size := visibleLen(word)
+		if i > 0 {
+			if c.wrap > 0 && n > visibleLen(rest) && n+1+size > c.wrap {
+				lines = append(lines, line)
+				line, n = rest, visibleLen(rest)
+			} else {
+				line += " "
+				n++
+			}
+		}
+		line += word
+		n += size
+	}
+	return append(lines, line)
+}
+
+// visibleLen returns the number of characters of s shown in a terminal
+func visibleLen(s string) int {
+	return utf8.RuneCountInString(ansiExp.ReplaceAllString(s, ""))
+}
@@ -0,0 +1,67 @@
+package gom2h
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+)
+
+// table of contents
+
+// [TOC] or <!-- toc --> on its own line is replaced by the table of contents
+var tocExp = regexp.MustCompile(`(?i)^(\[toc\]|<!--\s*toc\s*-->)$`)
+
+// Heading is a heading of a converted document
+type Heading struct {
+	Level int
+	Text  string
+	ID    string
+}
+
+// TOC renders headings as a nested list of links.
+// A heading nests under the closest preceding heading with a lower level.
+func TOC(headings []Heading) []byte {
+	if len(headings) == 0 {
+		return nil
+	}
+
+	ret := make([]byte, 0)
+	levels := make([]int, 0)
+	for idx, h := range headings {
+		if idx > 0 && h.Level <= levels[len(levels)-1] {
+			ret = append(ret, []byte(`</li>`)...)
+			for len(levels) > 1 && levels[len(levels)-2] >= h.Level {
+				levels = levels[:len(levels)-1]
+				ret = newline(ret)
+				ret = append(ret, []byte(`</ul>`)...)
+				ret = newline(ret)
+				ret = append(ret, []byte(`</li>`)...)
+			}
+		} else {
+			if idx > 0 {
+				ret = newline(ret)
+			}
+			ret = append(ret, []byte(`<ul>`)...)
+			levels = append(levels, h.Level)
+		}
+		ret = newline(ret)
+		ret = append(ret, []byte(fmt.Sprintf(`<li><a href="#%s">%s</a>`, html.EscapeString(h.ID), html.EscapeString(h.Text)))...)
+	}
+
+	ret = append(ret, []byte(`</li>`)...)
+	for ; len(levels) > 1; levels = levels[:len(levels)-1] {
+		ret = newline(ret)
+		ret = append(ret, []byte(`</ul>`)...)
+		ret = newline(ret)
+		ret = append(ret, []byte(`</li>`)...)
+	}
+	ret = newline(ret)
+	ret = append(ret, []byte(`</ul>`)...)
+
+	return ret
+}
+
+func isTOC(line []byte) bool {
+	return tocExp.Match(bytes.TrimSpace(line))
+}
@@ -0,0 +1,65 @@
+package gom2h
+
+import (
+	"regexp"
+
+	"github.com/matsuyoshi30/gom2h/ast"
+)
+
+// transformers
+//
+// Transformers modify the parsed document before it is rendered, e.g. to
+// rewrite links or collect images. They run in the order they were added.
+// Heading ids are assigned before and again after the transformers, so
+// added headings get ids as well.
+
+// Transformer modifies a parsed document
+type Transformer interface {
+	Transform(doc *ast.Document) error
+}
+
+// TransformerFunc adapts a function to a Transformer
+type TransformerFunc func(doc *ast.Document) error
+
+func (f TransformerFunc) Transform(doc *ast.Document) error {
+	return f(doc)
+}
+
+// WithTransformer adds t to the transformers run between parsing and rendering
+func WithTransformer(t Transformer) Option {
+	return func(c *converter) {
+		c.transformers = append(c.transformers, t)
+	}
+}
+
+var schemeExp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.\-]*:`)
+
+// IsAbsURL reports whether dest starts with a scheme like https: or
+// mailto:, so that it does not refer to a file relative to the document
+func IsAbsURL(dest string) bool {
+	return schemeExp.MatchString(dest)
+}
+
+// URLResolver returns the destination of the link or image n for dest,
+// e.g. to map links between markdown files to the converted files
+type URLResolver func(n ast.Node, dest string) string
+
+// WithURLResolver rewrites the destinations of links and images with r.
+// It runs as a transformer, in the order the transformers were added.
+func WithURLResolver(r URLResolver) Option {
+	return WithTransformer(TransformerFunc(func(doc *ast.Document) error {
+		ast.Walk(doc, func(n ast.Node, entering bool) ast.WalkStatus {
+			if !entering {
+				return ast.WalkContinue
+			}
+			switch n := n.(type) {
+			case *ast.Link:
+				n.Dest = []byte(r(n, string(n.Dest)))
+			case *ast.Image:
+				n.Dest = []byte(r(n, string(n.Dest)))
+			}
+			return ast.WalkContinue
+		})
+		return nil
+	}))
+}